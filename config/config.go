@@ -1 +1,457 @@
+// Package config loads typed configuration.
+//
+// Load fills a struct from, in increasing order of precedence, the default tags of its fields, config
+// files in the order they are given, environment variables and command-line flags. Fields are named by
+// their json tag or their snake case name, nested structs nest the names:
+//
+//	type Config struct {
+//		Port  int `default:"8080" desc:"HTTP port"`
+//		Queue struct {
+//			Addr     string `validate:"required"`
+//			Password string
+//		}
+//	}
+//
+// is read from the file keys port, queue.addr and queue.password, the env vars APP_PORT, APP_QUEUE_ADDR and
+// APP_QUEUE_PASSWORD with the prefix APP and the flags -port, -queue.addr and -queue.password. Files are YAML,
+// TOML or JSON by extension, their keys match in any case with or without underscores. Env vars and flags
+// separate list items with commas and map entries as key=value pairs. The loaded struct is validated with
+// the validate tags of the validate package.
 package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khvh/golain/validate"
+)
+
+// Source names where the value of a field came from
+type Source string
+
+// Sources in increasing order of precedence
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+const configFlag = "config"
+
+// Option configures a Loader
+type Option func(o *options)
+
+type options struct {
+	files     []file
+	envPrefix string
+	args      []string
+	name      string
+	poll      time.Duration
+}
+
+type file struct {
+	path     string
+	optional bool
+}
+
+// WithFiles reads config files in order, later files override earlier ones. Files can also be given with
+// the -config flag or the CONFIG env var, with the env prefix, as a comma separated list.
+func WithFiles(paths ...string) Option {
+	return func(o *options) {
+		for _, path := range paths {
+			o.files = append(o.files, file{path: path})
+		}
+	}
+}
+
+// WithOptionalFiles reads config files like WithFiles but skips the ones that don't exist
+func WithOptionalFiles(paths ...string) Option {
+	return func(o *options) {
+		for _, path := range paths {
+			o.files = append(o.files, file{path: path, optional: true})
+		}
+	}
+}
+
+// WithEnvPrefix sets the prefix of env vars, e.g. APP for APP_PORT
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithArgs sets the command-line arguments flags are parsed from, os.Args[1:] by default. Unknown flags are
+// ignored.
+func WithArgs(args ...string) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+// WithName sets the program name shown in the flag usage
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithPollInterval makes Watch poll the config files every d instead of watching them with inotify
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.poll = d
+	}
+}
+
+// ValidationError lists the fields of a loaded config that failed validation
+type ValidationError struct {
+	Errors validate.Errors
+	hints  map[string]string
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+
+		if hint, ok := e.hints[err.Field]; ok {
+			msgs[i] += " (" + hint + ")"
+		}
+	}
+
+	return "invalid config: " + strings.Join(msgs, ", ")
+}
+
+// Unwrap returns the validation errors
+func (e *ValidationError) Unwrap() error {
+	return e.Errors
+}
+
+// Loader loads configs of type T, it remembers where the values of the last loaded config came from
+type Loader[T any] struct {
+	opts    options
+	mu      sync.RWMutex
+	sources map[string]Source
+	args    []string
+	files   []file
+}
+
+// New creates a loader for configs of type T
+func New[T any](opts ...Option) *Loader[T] {
+	l := &Loader[T]{opts: options{args: os.Args[1:], name: os.Args[0]}}
+
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+
+	return l
+}
+
+// Load loads a config of type T, see the package documentation for where values come from
+func Load[T any](opts ...Option) (*T, error) {
+	return New[T](opts...).Load()
+}
+
+// Load loads a config, flag.ErrHelp is returned when the flags ask for help after the usage was printed
+func (l *Loader[T]) Load() (*T, error) {
+	cfg := new(T)
+
+	if reflect.TypeOf(cfg).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a struct, got %T", *cfg)
+	}
+
+	leaves, sections := fields(reflect.ValueOf(cfg), l.opts.envPrefix)
+	sources := map[string]Source{}
+
+	for _, f := range leaves {
+		if f.def == "" {
+			continue
+		}
+
+		if err := set(f.value, f.def); err != nil {
+			return nil, fmt.Errorf("default of %s: %w", f.key, err)
+		}
+
+		sources[f.key] = SourceDefault
+	}
+
+	flags, args, files, err := l.parseFlags(leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	files = append(append(append([]file{}, l.opts.files...), l.envFiles(leaves)...), files...)
+
+	for _, file := range files {
+		path := file.path
+
+		values, err := readFile(path)
+		if errors.Is(err, fs.ErrNotExist) && file.optional {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range leaves {
+			raw, ok := lookup(values, f.path)
+			if !ok {
+				continue
+			}
+
+			if err := assign(f.value, raw); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, f.key, err)
+			}
+
+			sources[f.key] = SourceFile
+		}
+	}
+
+	for _, f := range leaves {
+		s, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+
+		if err := set(f.value, s); err != nil {
+			return nil, fmt.Errorf("env %s: %w", f.env, err)
+		}
+
+		sources[f.key] = SourceEnv
+	}
+
+	for _, f := range leaves {
+		s, ok := flags[f.flag]
+		if !ok {
+			continue
+		}
+
+		if err := set(f.value, s); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", f.flag, err)
+		}
+
+		sources[f.key] = SourceFlag
+	}
+
+	unset(sections, sources)
+
+	l.mu.Lock()
+	l.sources, l.args, l.files = sources, args, files
+	l.mu.Unlock()
+
+	if err := validate.Struct(cfg); err != nil {
+		var errs validate.Errors
+
+		if errors.As(err, &errs) {
+			return cfg, newValidationError(errs, leaves)
+		}
+
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// Defaults sets the fields of the struct cfg points to that are zero to their default tag, for configs that
+// are built in code rather than loaded
+func Defaults(cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	leaves, sections := fields(v, "")
+	sources := map[string]Source{}
+
+	for _, f := range leaves {
+		if f.def == "" || !f.value.IsZero() {
+			continue
+		}
+
+		if err := set(f.value, f.def); err != nil {
+			return fmt.Errorf("default of %s: %w", f.key, err)
+		}
+
+		sources[f.key] = SourceDefault
+	}
+
+	unset(sections, sources)
+
+	return nil
+}
+
+// Args returns the arguments left after the flags, e.g. a subcommand
+func (l *Loader[T]) Args() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.args
+}
+
+// Source returns where the value of key came from in the last loaded config, empty for zero values
+func (l *Loader[T]) Source(key string) Source {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.sources[key]
+}
+
+// Files returns the paths of the files the last loaded config was read from, including optional files
+// that did not exist
+func (l *Loader[T]) Files() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	paths := make([]string, len(l.files))
+
+	for i, f := range l.files {
+		paths[i] = f.path
+	}
+
+	return paths
+}
+
+// parseFlags parses the flags of leaves, values are applied after files and env vars so they are only collected
+func (l *Loader[T]) parseFlags(leaves []*field) (map[string]string, []string, []file, error) {
+	fs := flag.NewFlagSet(l.opts.name, flag.ContinueOnError)
+	values := map[string]string{}
+
+	var files []file
+
+	for _, f := range leaves {
+		f := f
+		usage := f.desc
+
+		if usage == "" {
+			usage = f.key
+		}
+
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(f.flag, usage, func(s string) error {
+				values[f.flag] = s
+
+				return nil
+			})
+
+			continue
+		}
+
+		fs.Func(f.flag, usage, func(s string) error {
+			values[f.flag] = s
+
+			return nil
+		})
+	}
+
+	if fs.Lookup(configFlag) == nil {
+		fs.Func(configFlag, "config files, comma separated", func(s string) error {
+			for _, path := range strings.Split(s, ",") {
+				files = append(files, file{path: path})
+			}
+
+			return nil
+		})
+	}
+
+	if err := fs.Parse(knownFlags(fs, l.opts.args)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return values, fs.Args(), files, nil
+}
+
+// knownFlags drops the flags fs doesn't define from args, like unknown file keys and env vars they are
+// ignored, e.g. the -test.* flags of test binaries. The value of an unknown flag has to be given as
+// -name=value, otherwise it ends the flags as the first argument.
+func knownFlags(fs *flag.FlagSet, args []string) []string {
+	known := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(known, args[i:]...)
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+
+		if name == "h" || name == "help" {
+			known = append(known, arg)
+
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+
+		known = append(known, arg)
+
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); hasValue || ok && b.IsBoolFlag() {
+			continue
+		}
+
+		// the value of the flag is the next argument
+		if i+1 < len(args) {
+			i++
+			known = append(known, args[i])
+		}
+	}
+
+	return known
+}
+
+// envFiles returns the files in the CONFIG env var, unless the config has a field of that name
+func (l *Loader[T]) envFiles(leaves []*field) []file {
+	name := envName(l.opts.envPrefix, []string{configFlag})
+
+	for _, f := range leaves {
+		if f.env == name {
+			return nil
+		}
+	}
+
+	var files []file
+
+	for _, path := range strings.Split(os.Getenv(name), ",") {
+		if path != "" {
+			files = append(files, file{path: path})
+		}
+	}
+
+	return files
+}
+
+func newValidationError(errs validate.Errors, leaves []*field) *ValidationError {
+	byPath := map[string]*field{}
+
+	for _, f := range leaves {
+		byPath[f.validate] = f
+	}
+
+	e := &ValidationError{hints: map[string]string{}}
+
+	for _, err := range errs {
+		err := *err
+
+		if f, ok := byPath[err.Field]; ok {
+			err.Field = f.key
+			e.hints[f.key] = fmt.Sprintf("env %s, flag -%s", f.env, f.flag)
+		}
+
+		e.Errors = append(e.Errors, &err)
+	}
+
+	return e
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const redacted = "******"
+
+// Dump writes cfg as key = value lines with secrets and the passwords of URLs redacted, each line is
+// annotated with the description, the env var and the flag of the key and where its value came from
+func (l *Loader[T]) Dump(w io.Writer, cfg *T) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return dump(w, cfg, l.opts.envPrefix, l.sources)
+}
+
+// Dump writes cfg like Loader.Dump without the sources of the values, envPrefix is the prefix of the env vars
+func Dump(w io.Writer, cfg any, envPrefix string) error {
+	return dump(w, cfg, envPrefix, nil)
+}
+
+func dump(w io.Writer, cfg any, envPrefix string, sources map[string]Source) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+
+	// walking allocates the sections that are nil, cfg may be shared, e.g. by a Watcher
+	leaves, _ := fields(clone(v), envPrefix)
+
+	for _, f := range leaves {
+		value := format(f.value)
+
+		switch {
+		case f.secret && value != "":
+			value = redacted
+		case f.value.Kind() == reflect.String:
+			value = strconv.Quote(maskURL(value))
+		}
+
+		notes := []string{fmt.Sprintf("env %s, flag -%s", f.env, f.flag)}
+
+		if f.desc != "" {
+			notes = append([]string{f.desc}, notes...)
+		}
+
+		if source, ok := sources[f.key]; ok {
+			notes = append(notes, "from "+string(source))
+		}
+
+		if _, err := fmt.Fprintf(tw, "%s = %s\t# %s\n", f.key, value, strings.Join(notes, "; ")); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+// maskURL redacts the password and the secret query parameters of a URL, e.g. redis://:password@host:6379
+func maskURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return value
+	}
+
+	// url.Userinfo would escape the redaction
+	userinfo := ""
+
+	if _, ok := u.User.Password(); ok {
+		userinfo = url.User(u.User.Username()).String() + ":" + redacted + "@"
+		u.User = nil
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	masked := userinfo != ""
+
+	for i, p := range params {
+		if k, _, ok := strings.Cut(p, "="); ok && isSecret(k) {
+			params[i] = k + "=" + redacted
+			masked = true
+		}
+	}
+
+	if !masked {
+		return value
+	}
+
+	u.RawQuery = strings.Join(params, "&")
+
+	return strings.Replace(u.String(), "://", "://"+userinfo, 1)
+}
+
+// clone copies the struct v points to and the structs it points to, maps and slices are shared
+func clone(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+
+	cloneSections(c.Elem())
+
+	return c
+}
+
+func cloneSections(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+
+		switch {
+		case !f.CanSet():
+		case f.Kind() == reflect.Struct:
+			cloneSections(f)
+		case f.Kind() == reflect.Pointer && !f.IsNil() && f.Elem().Kind() == reflect.Struct:
+			f.Set(clone(f))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpMasksURLs(t *testing.T) {
+	cfg := struct {
+		Queue string
+		Cache string
+	}{
+		Queue: "redis://:hunter2@localhost:6379/0",
+		Cache: "redis-sentinel://a:1,b:2/master?db=1&sentinel_password=hunter2",
+	}
+
+	var buf bytes.Buffer
+
+	if err := Dump(&buf, &cfg, "APP"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	if strings.Contains(out, "hunter2") {
+		t.Fatalf("dump shows a password:\n%s", out)
+	}
+
+	for _, want := range []string{`"redis://:******@localhost:6379/0"`, `"redis-sentinel://a:1,b:2/master?db=1&sentinel_password=******"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump misses %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestMaskURL(t *testing.T) {
+	for _, value := range []string{"memory://", "localhost:6379", "postgres://user@db/app?sslmode=disable", "not a url"} {
+		if got := maskURL(value); got != value {
+			t.Errorf("maskURL(%q) = %q", value, got)
+		}
+	}
+}
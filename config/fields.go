@@ -0,0 +1,390 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/khvh/golain/validate"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	secretNames         = []string{"password", "secret", "token", "apikey", "privatekey"}
+)
+
+// field is a leaf of a config struct
+type field struct {
+	path     []string
+	key      string
+	env      string
+	flag     string
+	desc     string
+	def      string
+	secret   bool
+	validate string
+	value    reflect.Value
+}
+
+// section is a nil pointer to a struct that was allocated to reach its fields
+type section struct {
+	key   string
+	value reflect.Value
+}
+
+// fields returns the leaves of the struct v points to, keys are the json tags or the snake case field names.
+// Nil pointers to structs on the way are allocated and returned as sections.
+func fields(v reflect.Value, envPrefix string) ([]*field, []section) {
+	w := &walker{envPrefix: envPrefix}
+
+	w.walk(v.Elem(), nil, "")
+
+	return w.fields, w.sections
+}
+
+// unset sets sections without any value back to nil, so optional sections stay optional
+func unset(sections []section, sources map[string]Source) {
+	for i := len(sections) - 1; i >= 0; i-- {
+		s := sections[i]
+		used := false
+
+		for key := range sources {
+			if strings.HasPrefix(key, s.key+".") {
+				used = true
+
+				break
+			}
+		}
+
+		if !used {
+			s.value.Set(reflect.Zero(s.value.Type()))
+		}
+	}
+}
+
+type walker struct {
+	envPrefix string
+	fields    []*field
+	sections  []section
+}
+
+func (w *walker) walk(v reflect.Value, path []string, validatePath string) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			w.walk(fv, path, validatePath)
+
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(sf.Name)
+		}
+
+		p := append(append([]string{}, path...), name)
+		vp := join(validatePath, validate.FieldName(sf))
+
+		if isLeaf(sf.Type) {
+			w.fields = append(w.fields, &field{
+				path:     p,
+				key:      strings.Join(p, "."),
+				env:      envName(w.envPrefix, p),
+				flag:     strings.ReplaceAll(strings.Join(p, "."), "_", "-"),
+				desc:     sf.Tag.Get("desc"),
+				def:      sf.Tag.Get("default"),
+				secret:   sf.Tag.Get("secret") == "true" || isSecret(name),
+				validate: vp,
+				value:    fv,
+			})
+
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				fv.Set(reflect.New(sf.Type.Elem()))
+				w.sections = append(w.sections, section{key: strings.Join(p, "."), value: fv})
+			}
+
+			fv = fv.Elem()
+		}
+
+		w.walk(fv, p, vp)
+	}
+}
+
+// isLeaf reports whether values of t are set as a whole rather than field by field
+func isLeaf(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() != reflect.Struct
+}
+
+func isSecret(name string) bool {
+	n := normalize(name)
+
+	for _, s := range secretNames {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// set parses s into v, slices are comma separated and maps are comma separated key=value pairs
+func set(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return set(v.Elem(), s)
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+
+		if s != "" {
+			items = strings.Split(s, ",")
+		}
+
+		return setSlice(v, items)
+	case reflect.Map:
+		entries := map[string]string{}
+
+		for _, pair := range strings.Split(s, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q, expected key=value", pair)
+			}
+
+			entries[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+
+		return setMap(v, entries)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func setSlice(v reflect.Value, items []string) error {
+	s := reflect.MakeSlice(v.Type(), len(items), len(items))
+
+	for i, item := range items {
+		if err := set(s.Index(i), strings.TrimSpace(item)); err != nil {
+			return err
+		}
+	}
+
+	v.Set(s)
+
+	return nil
+}
+
+func setMap(v reflect.Value, entries map[string]string) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	m := reflect.MakeMapWithSize(v.Type(), len(entries))
+
+	for k, val := range entries {
+		key := reflect.New(v.Type().Key()).Elem()
+		key.SetString(k)
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := set(elem, val); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+
+		m.SetMapIndex(key, elem)
+	}
+
+	v.Set(m)
+
+	return nil
+}
+
+// format returns v the way it is written in env vars and flags
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+
+		v = v.Elem()
+	}
+
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, _ := m.MarshalText()
+
+		return string(b)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+
+		for i := range items {
+			items[i] = format(v.Index(i))
+		}
+
+		return strings.Join(items, ",")
+	case reflect.Map:
+		pairs := make([]string, 0, v.Len())
+		iter := v.MapRange()
+
+		for iter.Next() {
+			pairs = append(pairs, fmt.Sprintf("%v=%s", iter.Key(), format(iter.Value())))
+		}
+
+		sort.Strings(pairs)
+
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// snakeCase converts a Go field name like RedisURL to redis_url
+func snakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || startsWord(runes, i) && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// startsWord reports whether the upper case rune at i starts a word after an initialism, like the S of
+// HTTPServer but not the P of AllowIPs
+func startsWord(runes []rune, i int) bool {
+	if i+1 >= len(runes) || !unicode.IsLower(runes[i+1]) {
+		return false
+	}
+
+	plural := runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2]))
+
+	return !plural
+}
+
+// envName returns the env var of a key path, e.g. APP_QUEUE_ADDR
+func envName(prefix string, path []string) string {
+	name := strings.ToUpper(strings.Join(path, "_"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return '_'
+	}, name)
+
+	if prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(prefix, "_") + "_" + name
+}
+
+// normalize makes keys comparable across naming styles, queue_url, queue-url and queueURL are the same key
+func normalize(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
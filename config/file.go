@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads a YAML, TOML or JSON file, the format is picked by the extension
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		err = dec.Decode(&values)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// lookup finds the value of a key path in values decoded from a file, keys are matched with normalize
+func lookup(values map[string]any, path []string) (any, bool) {
+	var current any = values
+
+	for _, key := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		found := false
+
+		for k, v := range m {
+			if normalize(k) == normalize(key) {
+				current, found = v, true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// assign sets v to a value decoded from a file
+func assign(v reflect.Value, raw any) error {
+	kind := v.Kind()
+	if kind == reflect.Pointer {
+		kind = v.Type().Elem().Kind()
+	}
+
+	switch r := raw.(type) {
+	case nil:
+		return nil
+	case []any:
+		if kind != reflect.Slice {
+			return fmt.Errorf("expected %s, got a list", v.Type())
+		}
+
+		items := make([]string, len(r))
+
+		for i, item := range r {
+			items[i] = fmt.Sprint(item)
+		}
+
+		return setSlice(reflect.Indirect(v), items)
+	case map[string]any:
+		if kind != reflect.Map {
+			return fmt.Errorf("expected %s, got a table", v.Type())
+		}
+
+		entries := map[string]string{}
+
+		for k, item := range r {
+			entries[k] = fmt.Sprint(item)
+		}
+
+		return setMap(reflect.Indirect(v), entries)
+	default:
+		return set(v, fmt.Sprint(r))
+	}
+}
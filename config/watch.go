@@ -0,0 +1,269 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khvh/golain/logger"
+)
+
+const (
+	defaultPollInterval = 2 * time.Second
+	settleDelay         = 100 * time.Millisecond
+)
+
+// SubscriberFunc is called with the previous and the new config after a reload
+type SubscriberFunc[T any] func(old, new *T)
+
+// Watcher holds the latest config loaded by a Loader and reloads it when its files change
+type Watcher[T any] struct {
+	loader  *Loader[T]
+	current atomic.Pointer[T]
+	reload  sync.Mutex
+	mu      sync.RWMutex
+	subs    map[int]SubscriberFunc[T]
+	nextID  int
+	stop    chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+}
+
+// Watch loads a config of type T and reloads it when its files change, see Loader.Watch
+func Watch[T any](opts ...Option) (*Watcher[T], error) {
+	return New[T](opts...).Watch()
+}
+
+// Watch loads a config and reloads it when the files it was read from change. Files are watched with inotify
+// on Linux and polled elsewhere or when WithPollInterval is given. Env vars and flags are read again on every
+// reload. A config that fails to load or validate is logged and the previous one is kept.
+func (l *Loader[T]) Watch() (*Watcher[T], error) {
+	cfg, err := l.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher[T]{
+		loader: l,
+		subs:   map[int]SubscriberFunc[T]{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	w.current.Store(cfg)
+
+	files := l.Files()
+	if len(files) == 0 {
+		close(w.done)
+
+		return w, nil
+	}
+
+	n, err := newNotifier(files, l.opts.poll)
+	if err != nil {
+		logger.Component("config").Warn().Err(err).Msg("Config files are polled")
+
+		n = newPoller(defaultPollInterval)
+	}
+
+	go w.run(n, files, stat(files))
+
+	return w, nil
+}
+
+// Get returns the current config, it is replaced rather than changed on reload and must not be modified
+func (w *Watcher[T]) Get() *T {
+	return w.current.Load()
+}
+
+// Loader returns the loader of the watcher
+func (w *Watcher[T]) Loader() *Loader[T] {
+	return w.loader
+}
+
+// Subscribe calls fn after every reload that changed the config, fn runs on the goroutine that reloaded
+// and subscribers are called in the order they subscribed. The returned func unsubscribes fn.
+func (w *Watcher[T]) Subscribe(fn SubscriberFunc[T]) (unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.subs[id] = fn
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.subs, id)
+	}
+}
+
+// Reload loads the config again and notifies the subscribers when it changed, the current config is kept
+// when loading fails
+func (w *Watcher[T]) Reload() error {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+
+	cfg, err := w.loader.Load()
+	if err != nil {
+		return err
+	}
+
+	old := w.current.Load()
+	if reflect.DeepEqual(old, cfg) {
+		return nil
+	}
+
+	w.current.Store(cfg)
+
+	logger.Component("config").Info().Msg("Config reloaded")
+
+	for _, fn := range w.subscribers() {
+		fn(old, cfg)
+	}
+
+	return nil
+}
+
+// subscribers returns the subscribers in the order they subscribed
+func (w *Watcher[T]) subscribers() []SubscriberFunc[T] {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	fns := make([]SubscriberFunc[T], 0, len(w.subs))
+
+	for id := 0; id < w.nextID; id++ {
+		if fn, ok := w.subs[id]; ok {
+			fns = append(fns, fn)
+		}
+	}
+
+	return fns
+}
+
+// Close stops watching the files
+func (w *Watcher[T]) Close() error {
+	w.closed.Do(func() {
+		close(w.stop)
+	})
+
+	<-w.done
+
+	return nil
+}
+
+func (w *Watcher[T]) run(n notifier, files []string, last []fileState) {
+	defer close(w.done)
+	defer n.Close()
+
+	log := logger.Component("config")
+
+	// files that changed after the first load but before they were watched
+	if err := w.Reload(); err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping the current config")
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-n.Events():
+		}
+
+		// editors and config map updates change files in several steps
+		timer := time.NewTimer(settleDelay)
+
+	settle:
+		for {
+			select {
+			case <-w.stop:
+				timer.Stop()
+
+				return
+			case <-n.Events():
+			case <-timer.C:
+				break settle
+			}
+		}
+
+		current := stat(files)
+		if reflect.DeepEqual(last, current) {
+			continue
+		}
+
+		last = current
+
+		if err := w.Reload(); err != nil {
+			log.Error().Err(err).Msg("Config reload failed, keeping the current config")
+		}
+	}
+}
+
+// fileState tells whether a file changed, a missing file has the zero state
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// stat follows symlinks, so swapping the target of a link like Kubernetes does for config maps is a change
+func stat(files []string) []fileState {
+	states := make([]fileState, len(files))
+
+	for i, path := range files {
+		if fi, err := os.Stat(path); err == nil {
+			states[i] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+		}
+	}
+
+	return states
+}
+
+// notifier sends on Events when watched files may have changed
+type notifier interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// poller sends on Events every interval
+type poller struct {
+	events chan struct{}
+	stop   chan struct{}
+}
+
+func newPoller(interval time.Duration) *poller {
+	p := &poller{events: make(chan struct{}, 1), stop: make(chan struct{})}
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				select {
+				case p.events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return p
+}
+
+// Events implements notifier
+func (p *poller) Events() <-chan struct{} {
+	return p.events
+}
+
+// Close implements notifier
+func (p *poller) Close() error {
+	close(p.stop)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotify watches the directories of the files, so files that are replaced by renames or symlink swaps
+// and files that don't exist yet are noticed too
+type inotify struct {
+	file   *os.File
+	events chan struct{}
+}
+
+func newNotifier(files []string, poll time.Duration) (notifier, error) {
+	if poll > 0 {
+		return newPoller(poll), nil
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	dirs := map[string]bool{}
+
+	for _, path := range files {
+		dir := filepath.Dir(path)
+
+		if dirs[dir] {
+			continue
+		}
+
+		dirs[dir] = true
+
+		mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_ATTRIB)
+		if _, err := unix.InotifyAddWatch(fd, dir, mask); err != nil {
+			unix.Close(fd)
+
+			return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+	}
+
+	n := &inotify{file: os.NewFile(uintptr(fd), "inotify"), events: make(chan struct{}, 1)}
+
+	go n.read()
+
+	return n, nil
+}
+
+// read signals every batch of events until the file is closed, the events themselves don't matter as the
+// watcher compares the files
+func (n *inotify) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Events implements notifier
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close implements notifier
+func (n *inotify) Close() error {
+	return n.file.Close()
+}
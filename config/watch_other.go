@@ -0,0 +1,13 @@
+//go:build !linux
+
+package config
+
+import "time"
+
+func newNotifier(files []string, poll time.Duration) (notifier, error) {
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+
+	return newPoller(poll), nil
+}
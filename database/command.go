@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: migrate COMMAND [-dry-run] [ARG]
+
+commands:
+  up [VERSION]    apply the pending migrations, up to VERSION when given
+  down [STEPS]    roll back the last STEPS applied migrations, 1 by default
+  status          list the migrations and whether they are applied
+
+-dry-run prints the SQL of up and down instead of running it
+`
+
+// Command runs a migrate command given as command line arguments, e.g. up, down 2 or status -dry-run, and
+// writes its output to w. It lets a service migrate its database from its own binary.
+func (m *Migrator) Command(ctx context.Context, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(w, migrateUsage)
+
+		return errors.New("missing migrate command")
+	}
+
+	command := args[0]
+
+	flags := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	flags.SetOutput(w)
+	flags.Usage = func() {
+		fmt.Fprint(w, migrateUsage)
+	}
+
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+
+	// flags may come before or after the argument
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var arg string
+
+	if rest := flags.Args(); len(rest) > 0 {
+		arg = rest[0]
+
+		if err := flags.Parse(rest[1:]); err != nil {
+			return err
+		}
+
+		if flags.NArg() > 0 {
+			return fmt.Errorf("unexpected arguments %v", flags.Args())
+		}
+	}
+
+	run := *m
+	if *dryRun {
+		run.dryRun = w
+	}
+
+	switch command {
+	case "up":
+		version := int64(math.MaxInt64)
+
+		if arg != "" {
+			v, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version %q", arg)
+			}
+
+			version = v
+		}
+
+		done, err := run.UpTo(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		report(w, done, "applied", *dryRun)
+
+		return nil
+	case "down":
+		steps := 1
+
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid steps %q", arg)
+			}
+
+			steps = n
+		}
+
+		done, err := run.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+
+		report(w, done, "rolled back", *dryRun)
+
+		return nil
+	case "status":
+		if arg != "" {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MIGRATION\tSTATUS\tAPPLIED AT")
+
+		for _, s := range statuses {
+			status, at := "pending", ""
+
+			switch {
+			case s.Missing:
+				status = "applied, file missing"
+			case s.Changed:
+				status = "applied, changed"
+			case s.Applied:
+				status = "applied"
+			}
+
+			if s.Applied && !s.AppliedAt.IsZero() {
+				at = s.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", s, status, at)
+		}
+
+		return tw.Flush()
+	default:
+		fmt.Fprint(w, migrateUsage)
+
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
+
+// report lists the migrations a command ran, a dry run already printed their SQL
+func report(w io.Writer, done []*Migration, verb string, dryRun bool) {
+	if dryRun {
+		return
+	}
+
+	if len(done) == 0 {
+		fmt.Fprintln(w, "no migrations", verb)
+
+		return
+	}
+
+	for _, mig := range done {
+		fmt.Fprintln(w, verb, mig)
+	}
+}
@@ -1 +1,162 @@
+// Package database opens pooled PostgreSQL, MySQL and SQLite connections through database/sql. Opening waits
+// for the database with retries, every query gets a span and is timed in a histogram and Ready tells whether
+// the database can be reached.
 package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/khvh/golain/logger"
+	"go.opentelemetry.io/otel/metric"
+
+	// drivers of the supported databases
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Driver selects the database
+type Driver string
+
+// Supported drivers
+const (
+	DriverPostgres Driver = "postgres"
+	DriverMySQL    Driver = "mysql"
+	DriverSQLite   Driver = "sqlite"
+)
+
+// driverNames are the database/sql names the driver packages register
+var driverNames = map[Driver]string{
+	DriverPostgres: "pgx",
+	DriverMySQL:    "mysql",
+	DriverSQLite:   "sqlite",
+}
+
+const (
+	defaultRetryTimeout     = 30 * time.Second
+	defaultRetryInterval    = 500 * time.Millisecond
+	defaultRetryMaxInterval = 10 * time.Second
+)
+
+// Config configures a database.
+//
+// DSN is a postgres:// URL or key=value string for PostgreSQL, user:password@tcp(host:port)/db for MySQL and
+// a file path or file: URI for SQLite, where ":memory:" needs MaxOpenConns 1 to keep a single database. Pool
+// settings left zero keep the database/sql defaults. Open pings the database until it answers, waiting
+// RetryInterval, 500ms by default, doubled after every attempt up to 10s, for at most RetryTimeout, 30s by
+// default, a negative RetryTimeout pings once. Query metrics are recorded through MeterProvider, the global
+// one by default.
+type Config struct {
+	Driver          Driver               `validate:"omitempty,enum=postgres|mysql|sqlite" desc:"postgres, mysql or sqlite"`
+	DSN             string               `secret:"true" desc:"data source name"`
+	MaxOpenConns    int                  `desc:"maximum number of open connections"`
+	MaxIdleConns    int                  `desc:"maximum number of idle connections"`
+	ConnMaxLifetime time.Duration        `desc:"time after which connections are closed"`
+	ConnMaxIdleTime time.Duration        `desc:"idle time after which connections are closed"`
+	RetryTimeout    time.Duration        `desc:"how long opening waits for the database"`
+	RetryInterval   time.Duration        `desc:"first wait between connection attempts"`
+	MeterProvider   metric.MeterProvider `json:"-"`
+}
+
+// Open opens a database as described by cfg, see OpenContext
+func Open(cfg Config) (*DB, error) {
+	return OpenContext(context.Background(), cfg)
+}
+
+// OpenContext opens a database as described by cfg and waits until it answers or ctx is done
+func OpenContext(ctx context.Context, cfg Config) (*DB, error) {
+	name, ok := driverNames[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	pool, err := sql.Open(name, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxOpenConns != 0 {
+		pool.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns != 0 {
+		pool.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if cfg.ConnMaxLifetime != 0 {
+		pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if cfg.ConnMaxIdleTime != 0 {
+		pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if err := connect(ctx, pool, cfg); err != nil {
+		pool.Close()
+
+		return nil, err
+	}
+
+	return newDB(pool, cfg), nil
+}
+
+// connect pings the database with exponential backoff until it answers
+func connect(ctx context.Context, pool *sql.DB, cfg Config) error {
+	timeout, wait := cfg.RetryTimeout, cfg.RetryInterval
+
+	if timeout == 0 {
+		timeout = defaultRetryTimeout
+	}
+
+	if wait <= 0 {
+		wait = defaultRetryInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for attempt := 1; ; attempt++ {
+		err := pool.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if timeout < 0 || time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("connecting to %s: %w", cfg.Driver, err)
+		}
+
+		logger.Component("database").Warn().Err(err).Int("attempt", attempt).Dur("retry_in", wait).Msg("Database is not ready")
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(wait):
+		}
+
+		if wait *= 2; wait > defaultRetryMaxInterval {
+			wait = defaultRetryMaxInterval
+		}
+	}
+}
+
+// Ready pings the database, it fails when the database can't be reached
+func (db *DB) Ready(ctx context.Context) error {
+	return db.PingContext(ctx)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying db
+func NewContext(ctx context.Context, db *DB) context.Context {
+	return context.WithValue(ctx, contextKey{}, db)
+}
+
+// FromContext returns the database in ctx, nil when there is none
+func FromContext(ctx context.Context) *DB {
+	db, _ := ctx.Value(contextKey{}).(*DB)
+
+	return db
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func openTest(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(Config{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "test.db"), RetryTimeout: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func TestOpenUnsupportedDriver(t *testing.T) {
+	if _, err := Open(Config{Driver: "oracle"}); err == nil {
+		t.Fatal("expected an error for an unsupported driver")
+	}
+}
+
+func TestOpenFails(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "missing", "test.db")
+	start := time.Now()
+
+	if _, err := Open(Config{Driver: DriverSQLite, DSN: dsn, RetryTimeout: -1}); err == nil {
+		t.Fatal("expected an error for a database that can't be reached")
+	}
+
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("a negative retry timeout should ping once, took %s", took)
+	}
+}
+
+func TestOpenGivesUpAfterRetryTimeout(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "missing", "test.db")
+	start := time.Now()
+
+	_, err := Open(Config{Driver: DriverSQLite, DSN: dsn, RetryTimeout: 200 * time.Millisecond, RetryInterval: 20 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected an error for a database that can't be reached")
+	}
+
+	if took := time.Since(start); took < 100*time.Millisecond || took > time.Second {
+		t.Errorf("expected to retry for about 200ms, took %s", took)
+	}
+}
+
+func TestOpenRetries(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.Mkdir(dir, 0o755)
+	}()
+
+	db, err := Open(Config{
+		Driver:        DriverSQLite,
+		DSN:           filepath.Join(dir, "test.db"),
+		RetryTimeout:  5 * time.Second,
+		RetryInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if err := db.Ready(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	dsn := filepath.Join(t.TempDir(), "missing", "test.db")
+
+	if _, err := OpenContext(ctx, Config{Driver: DriverSQLite, DSN: dsn, RetryInterval: 20 * time.Millisecond}); err == nil {
+		t.Fatal("expected an error when the context is done")
+	}
+}
+
+func TestReady(t *testing.T) {
+	db := openTest(t)
+
+	if err := db.Ready(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	if err := db.Ready(context.Background()); err == nil {
+		t.Fatal("expected a closed database not to be ready")
+	}
+}
+
+func TestContext(t *testing.T) {
+	db := openTest(t)
+
+	if FromContext(context.Background()) != nil {
+		t.Error("expected no database in an empty context")
+	}
+
+	if got := FromContext(NewContext(context.Background(), db)); got != db {
+		t.Errorf("expected the database of the context, got %v", got)
+	}
+}
+
+func TestQueriesAreTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	db := openTest(t)
+
+	if _, err := db.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO items (name) VALUES (?)", "one"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+
+	if err := db.QueryRow("SELECT name FROM items").Scan(&name); err != nil || name != "one" {
+		t.Fatalf("expected one, got %q: %v", name, err)
+	}
+
+	if _, err := db.Exec("SELECT * FROM missing"); err == nil {
+		t.Fatal("expected an error for a missing table")
+	}
+
+	spans := recorder.Ended()
+	want := []string{"CREATE", "INSERT", "SELECT", "SELECT"}
+
+	if len(spans) != len(want) {
+		t.Fatalf("expected %d spans, got %d", len(want), len(spans))
+	}
+
+	for i, span := range spans {
+		if span.Name() != want[i] {
+			t.Errorf("span %d: expected name %s, got %s", i, want[i], span.Name())
+		}
+
+		attrs := attribute.NewSet(span.Attributes()...)
+
+		if v, _ := attrs.Value("db.system"); v.AsString() != "sqlite" {
+			t.Errorf("span %d: expected db.system sqlite, got %q", i, v.AsString())
+		}
+
+		if v, _ := attrs.Value("db.operation"); v.AsString() != want[i] {
+			t.Errorf("span %d: expected db.operation %s, got %q", i, want[i], v.AsString())
+		}
+	}
+
+	if spans[3].Status().Code != codes.Error {
+		t.Error("expected the failed query to set an error status")
+	}
+
+	if spans[2].Status().Code == codes.Error {
+		t.Error("expected the successful query not to set an error status")
+	}
+}
+
+func TestOperation(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1":                      "SELECT",
+		"  insert into t values (1)":    "INSERT",
+		"(SELECT 1) UNION (SELECT 2)":   "SELECT",
+		"WITH x AS (SELECT 1) SELECT 1": "WITH",
+		"":                              "",
+	}
+
+	for query, want := range tests {
+		if got := operation(query); got != want {
+			t.Errorf("operation(%q): expected %q, got %q", query, want, got)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/khvh/golain/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/khvh/golain/database"
+
+// systems are the db.system attributes of the drivers
+var systems = map[Driver]attribute.KeyValue{
+	DriverPostgres: semconv.DBSystemPostgreSQL,
+	DriverMySQL:    semconv.DBSystemMySQL,
+	DriverSQLite:   semconv.DBSystemKey.String("sqlite"),
+}
+
+// DB is a pool of connections. Its query methods, and those of its transactions and statements, add a span
+// and record the duration of every query in the db.client.duration histogram. Rows are timed until the query
+// returned, not until they are read.
+type DB struct {
+	*sql.DB
+	driver   Driver
+	system   attribute.KeyValue
+	tracer   trace.Tracer
+	duration *telemetry.Histogram
+}
+
+func newDB(pool *sql.DB, cfg Config) *DB {
+	mp := cfg.MeterProvider
+	if mp == nil {
+		mp = global.MeterProvider()
+	}
+
+	return &DB{
+		DB:       pool,
+		driver:   cfg.Driver,
+		system:   systems[cfg.Driver],
+		tracer:   otel.Tracer(instrumentationName),
+		duration: telemetry.NewMeter(mp, instrumentationName).Histogram("db.client.duration", "How long queries took", unit.Milliseconds),
+	}
+}
+
+// Driver returns the driver of the database
+func (db *DB) Driver() Driver {
+	return db.driver
+}
+
+// observe starts the span of query, the returned func ends it and records the duration
+func (db *DB) observe(ctx context.Context, query string) (context.Context, func(err error)) {
+	op := operation(query)
+	name := op
+
+	if name == "" {
+		name = db.system.Value.AsString()
+	}
+
+	attrs := []attribute.KeyValue{db.system}
+
+	if op != "" {
+		attrs = append(attrs, semconv.DBOperationKey.String(op))
+	}
+
+	ctx, span := db.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, semconv.DBStatementKey.String(query))...),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		failed := err != nil && !errors.Is(err, sql.ErrNoRows)
+
+		if failed {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+
+		db.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), append(attrs, attribute.Bool("error", failed))...)
+	}
+}
+
+// operation returns the first keyword of query in upper case, e.g. SELECT
+func operation(query string) string {
+	query = strings.TrimLeftFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '('
+	})
+
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	if end < 0 {
+		end = len(query)
+	}
+
+	return strings.ToUpper(query[:end])
+}
+
+// ExecContext executes a query without returning rows
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := db.observe(ctx, query)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	done(err)
+
+	return res, err
+}
+
+// Exec executes a query without returning rows
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query that returns rows
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := db.observe(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	done(err)
+
+	return rows, err
+}
+
+// Query executes a query that returns rows
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := db.observe(ctx, query)
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+
+	return row
+}
+
+// QueryRow executes a query that returns at most one row
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// PrepareContext creates a prepared statement
+func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{Stmt: stmt, db: db, query: query}, nil
+}
+
+// Prepare creates a prepared statement
+func (db *DB) Prepare(query string) (*Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// BeginTx starts a transaction
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+// Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// Tx is a transaction whose queries are traced and timed like those of DB
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// ExecContext executes a query without returning rows
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := tx.db.observe(ctx, query)
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	done(err)
+
+	return res, err
+}
+
+// Exec executes a query without returning rows
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query that returns rows
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := tx.db.observe(ctx, query)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	done(err)
+
+	return rows, err
+}
+
+// Query executes a query that returns rows
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := tx.db.observe(ctx, query)
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+
+	return row
+}
+
+// QueryRow executes a query that returns at most one row
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// PrepareContext creates a prepared statement used within the transaction
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	stmt, err := tx.Tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{Stmt: stmt, db: tx.db, query: query}, nil
+}
+
+// Prepare creates a prepared statement used within the transaction
+func (tx *Tx) Prepare(query string) (*Stmt, error) {
+	return tx.PrepareContext(context.Background(), query)
+}
+
+// Stmt is a prepared statement whose executions are traced and timed like the queries of DB
+type Stmt struct {
+	*sql.Stmt
+	db    *DB
+	query string
+}
+
+// ExecContext executes the statement without returning rows
+func (s *Stmt) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	ctx, done := s.db.observe(ctx, s.query)
+	res, err := s.Stmt.ExecContext(ctx, args...)
+	done(err)
+
+	return res, err
+}
+
+// Exec executes the statement without returning rows
+func (s *Stmt) Exec(args ...any) (sql.Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// QueryContext executes the statement and returns rows
+func (s *Stmt) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	ctx, done := s.db.observe(ctx, s.query)
+	rows, err := s.Stmt.QueryContext(ctx, args...)
+	done(err)
+
+	return rows, err
+}
+
+// Query executes the statement and returns rows
+func (s *Stmt) Query(args ...any) (*sql.Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryRowContext executes the statement and returns at most one row
+func (s *Stmt) QueryRowContext(ctx context.Context, args ...any) *sql.Row {
+	ctx, done := s.db.observe(ctx, s.query)
+	row := s.Stmt.QueryRowContext(ctx, args...)
+	done(row.Err())
+
+	return row
+}
+
+// QueryRow executes the statement and returns at most one row
+func (s *Stmt) QueryRow(args ...any) *sql.Row {
+	return s.QueryRowContext(context.Background(), args...)
+}
@@ -0,0 +1,587 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khvh/golain/logger"
+)
+
+const defaultMigrationsTable = "schema_migrations"
+
+// ErrChecksumMismatch is returned when an applied migration was edited afterwards
+var ErrChecksumMismatch = errors.New("migration was changed after it was applied")
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	identifier    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+// Migration is a versioned schema change, Checksum is the hex SHA-256 of Up and Down so editing either is
+// detected
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// String returns the file name of m without direction, e.g. 0001_create_users
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// LoadMigrations reads the migrations in fsys, e.g. an embed.FS, sorted by version. Every version has a
+// VERSION_NAME.up.sql file and optionally a VERSION_NAME.down.sql file, e.g. 0001_create_users.up.sql, in
+// any directory. Files that don't end in .sql are ignored.
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	byVersion := map[int64]*Migration{}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".sql" {
+			return err
+		}
+
+		match := migrationFile.FindStringSubmatch(d.Name())
+		if match == nil {
+			return fmt.Errorf("%s: migration files are named VERSION_NAME.up.sql or VERSION_NAME.down.sql", p)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		body, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return fmt.Errorf("%s: version %d is also used by %s", p, version, m)
+		}
+
+		if match[3] == "up" {
+			if m.Up != "" {
+				return fmt.Errorf("%s: duplicate up migration", p)
+			}
+
+			m.Up = string(body)
+		} else {
+			if m.Down != "" {
+				return fmt.Errorf("%s: duplicate down migration", p)
+			}
+
+			m.Down = string(body)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+
+		sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+		m.Checksum = hex.EncodeToString(sum[:])
+
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateOption configures a Migrator
+type MigrateOption func(m *Migrator)
+
+// WithMigrationsTable sets the table applied migrations are tracked in, schema_migrations by default
+func WithMigrationsTable(table string) MigrateOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithDryRun writes the SQL of the migrations to w instead of running it
+func WithDryRun(w io.Writer) MigrateOption {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+// Migrator applies and rolls back migrations. The applied ones are tracked in a table with their checksum,
+// applying stops when one of them was edited. Every migration runs in a transaction, MySQL commits DDL
+// statements implicitly though, and its DSN needs multiStatements=true for migrations with several
+// statements. Replicas migrating at once are serialized with an advisory lock on PostgreSQL and MySQL,
+// SQLite has none, so an SQLite database must be migrated by one process at a time.
+type Migrator struct {
+	db         *DB
+	migrations []*Migration
+	table      string
+	dryRun     io.Writer
+}
+
+// NewMigrator returns a migrator of db with the migrations in fsys, see LoadMigrations
+func NewMigrator(db *DB, fsys fs.FS, opts ...MigrateOption) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{db: db, migrations: migrations, table: defaultMigrationsTable}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if !identifier.MatchString(m.table) {
+		return nil, fmt.Errorf("invalid migrations table %q", m.table)
+	}
+
+	return m, nil
+}
+
+// Migrations returns the migrations sorted by version
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// MigrationStatus tells whether a migration is applied. Changed is set when the migration was edited after it
+// was applied, Missing when an applied migration has no file anymore.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Changed   bool
+	Missing   bool
+}
+
+// String returns the file name of the migration without direction, e.g. 0001_create_users
+func (s MigrationStatus) String() string {
+	return fmt.Sprintf("%04d_%s", s.Version, s.Name)
+}
+
+// Status lists the migrations and the applied ones without file, sorted by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	exists, err := m.tableExists(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int64]appliedMigration{}
+
+	if exists {
+		if applied, err = m.applied(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.status(applied), nil
+}
+
+// Up applies all pending migrations and returns them
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	return m.UpTo(ctx, math.MaxInt64)
+}
+
+// UpTo applies the pending migrations up to and including version and returns them
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]*Migration, error) {
+	var done []*Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		if err := checksums(m.status(applied)); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+
+			if err := m.run(ctx, conn, mig, true); err != nil {
+				return err
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			if mig.Down == "" {
+				return fmt.Errorf("migration %s has no down file", mig)
+			}
+
+			if err := m.run(ctx, conn, mig, false); err != nil {
+				return err
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// locked runs fn with the migrations applied so far while holding the migration lock. A dry run reads the
+// applied migrations without locking or creating the table.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]appliedMigration) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if m.dryRun == nil {
+		unlock, err := m.lock(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+
+		if err := m.createTable(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	applied := map[int64]appliedMigration{}
+
+	if exists, err := m.tableExists(ctx, conn); err != nil {
+		return err
+	} else if exists {
+		if applied, err = m.applied(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+// run applies or rolls back mig in a transaction that also updates the migrations table
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mig *Migration, up bool) error {
+	query, direction := mig.Up, "up"
+	if !up {
+		query, direction = mig.Down, "down"
+	}
+
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %s %s\n%s\n", mig, direction, query)
+
+		return err
+	}
+
+	start := time.Now()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.exec(ctx, tx, query); err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("migration %s %s: %w", mig, direction, err)
+	}
+
+	if up {
+		_, err = m.exec(ctx, tx, fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES (%s, %s, %s)",
+			m.table, m.placeholder(1), m.placeholder(2), m.placeholder(3)), mig.Version, mig.Name, mig.Checksum)
+	} else {
+		_, err = m.exec(ctx, tx, fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.table, m.placeholder(1)), mig.Version)
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		return fmt.Errorf("migration %s %s: %w", mig, direction, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %s %s: %w", mig, direction, err)
+	}
+
+	logger.Component("database").Info().
+		Int64("version", mig.Version).
+		Str("name", mig.Name).
+		Str("direction", direction).
+		Dur("took", time.Since(start)).
+		Msg("Migration applied")
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// exec runs query on a connection of the migrator, traced and timed like the queries of DB
+func (m *Migrator) exec(ctx context.Context, e execer, query string, args ...any) (sql.Result, error) {
+	ctx, done := m.db.observe(ctx, query)
+	res, err := e.ExecContext(ctx, query, args...)
+	done(err)
+
+	return res, err
+}
+
+// lock takes the advisory lock of the migrations table, it waits while another process holds it
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (unlock func(), err error) {
+	name := "migrate:" + m.table
+
+	var lockQuery, unlockQuery string
+	var arg any
+
+	switch m.db.driver {
+	case DriverPostgres:
+		h := fnv.New64a()
+		h.Write([]byte(name))
+
+		lockQuery, unlockQuery, arg = "SELECT pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", int64(h.Sum64())
+	case DriverMySQL:
+		lockQuery, unlockQuery, arg = "SELECT GET_LOCK(?, -1)", "SELECT RELEASE_LOCK(?)", name
+	default:
+		return func() {}, nil
+	}
+
+	var locked sql.NullInt64
+
+	if m.db.driver == DriverMySQL {
+		err = conn.QueryRowContext(ctx, lockQuery, arg).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = errors.New("GET_LOCK failed")
+		}
+	} else {
+		_, err = m.exec(ctx, conn, lockQuery, arg)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("locking migrations: %w", err)
+	}
+
+	return func() {
+		// the lock belongs to the session, it must be released even when ctx is done
+		if _, err := conn.ExecContext(context.Background(), unlockQuery, arg); err != nil {
+			logger.Component("database").Warn().Err(err).Msg("Migration lock was not released")
+		}
+	}, nil
+}
+
+// createTable creates the migrations table when it doesn't exist
+func (m *Migrator) createTable(ctx context.Context, e execer) error {
+	text, checksum := "TEXT", "TEXT"
+	if m.db.driver == DriverMySQL {
+		text, checksum = "VARCHAR(255)", "CHAR(64)"
+	}
+
+	_, err := m.exec(ctx, e, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name %s NOT NULL,
+	checksum %s NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`, m.table, text, checksum))
+
+	return err
+}
+
+// tableExists tells whether the migrations table was created
+func (m *Migrator) tableExists(ctx context.Context, q querier) (bool, error) {
+	schema, table, qualified := strings.Cut(m.table, ".")
+	if !qualified {
+		schema, table = "", m.table
+	}
+
+	var query string
+	var args []any
+
+	switch m.db.driver {
+	case DriverPostgres:
+		query, args = "SELECT COUNT(*) FROM pg_catalog.pg_class WHERE oid = to_regclass($1)", []any{m.table}
+	case DriverMySQL:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ? AND table_schema = COALESCE(NULLIF(?, ''), DATABASE())"
+		args = []any{table, schema}
+	default:
+		query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE type = 'table' AND name = ?", sqliteMaster(schema))
+		args = []any{table}
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	var n int
+
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			return false, err
+		}
+	}
+
+	return n > 0, rows.Err()
+}
+
+// sqliteMaster returns the schema table of an attached SQLite database
+func sqliteMaster(schema string) string {
+	if schema == "" {
+		return "sqlite_master"
+	}
+
+	return schema + ".sqlite_master"
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// applied reads the migrations table
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int64]appliedMigration, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		var at any
+
+		if err := rows.Scan(&version, &a.name, &a.checksum, &at); err != nil {
+			return nil, err
+		}
+
+		a.appliedAt = timestamp(at)
+		applied[version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// timestamp converts applied_at, which MySQL returns as text unless the DSN has parseTime=true
+func timestamp(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case []byte:
+		return timestamp(string(t))
+	case string:
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// status merges the migrations with the applied ones
+func (m *Migrator) status(applied map[int64]appliedMigration) []MigrationStatus {
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := map[int64]bool{}
+
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied, s.AppliedAt, s.Changed = true, a.appliedAt, a.checksum != mig.Checksum
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	for version, a := range applied {
+		if !known[version] {
+			statuses = append(statuses, MigrationStatus{Version: version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses
+}
+
+// checksums fails for every applied migration that was edited
+func checksums(statuses []MigrationStatus) error {
+	var errs []error
+
+	for _, s := range statuses {
+		if s.Changed {
+			errs = append(errs, fmt.Errorf("%s: %w", s, ErrChecksumMismatch))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// placeholder returns the nth bind parameter of the driver
+func (m *Migrator) placeholder(n int) string {
+	if m.db.driver == DriverPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
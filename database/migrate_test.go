@@ -0,0 +1,259 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/0001_create_items.up.sql":   {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);\nCREATE TABLE tags (name TEXT);")},
+		"migrations/0001_create_items.down.sql": {Data: []byte("DROP TABLE tags;\nDROP TABLE items;")},
+		"migrations/0002_add_name.up.sql":       {Data: []byte("ALTER TABLE items ADD COLUMN name TEXT;")},
+		"migrations/0002_add_name.down.sql":     {Data: []byte("ALTER TABLE items DROP COLUMN name;")},
+		"migrations/README.md":                  {Data: []byte("ignored")},
+	}
+}
+
+func newTestMigrator(t *testing.T, db *DB, fsys fstest.MapFS, opts ...MigrateOption) *Migrator {
+	t.Helper()
+
+	m, err := NewMigrator(db, fsys, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func applied(t *testing.T, m *Migrator) []string {
+	t.Helper()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, s := range statuses {
+		if s.Applied {
+			names = append(names, s.String())
+		}
+	}
+
+	return names
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 2 || migrations[0].String() != "0001_create_items" || migrations[1].String() != "0002_add_name" {
+		t.Fatalf("unexpected migrations %v", migrations)
+	}
+
+	tests := map[string]fstest.MapFS{
+		"bad name":  {"1-create.up.sql": {Data: []byte("SELECT 1")}},
+		"no up":     {"0001_x.down.sql": {Data: []byte("SELECT 1")}},
+		"duplicate": {"0001_x.up.sql": {Data: []byte("SELECT 1")}, "0001_y.up.sql": {Data: []byte("SELECT 1")}},
+	}
+
+	for name, fsys := range tests {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+	m := newTestMigrator(t, db, testMigrations())
+
+	if got := applied(t, m); len(got) != 0 {
+		t.Fatalf("expected no applied migrations, got %v", got)
+	}
+
+	done, err := m.UpTo(ctx, 1)
+	if err != nil || len(done) != 1 {
+		t.Fatalf("expected to apply 1 migration, got %v: %v", done, err)
+	}
+
+	if done, err = m.Up(ctx); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("expected to apply migration 2, got %v: %v", done, err)
+	}
+
+	if done, err = m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("expected nothing to apply, got %v: %v", done, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO items (name) VALUES ('one')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(applied(t, m), ","); got != "0001_create_items,0002_add_name" {
+		t.Fatalf("unexpected applied migrations %s", got)
+	}
+
+	if done, err = m.Down(ctx, 1); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("expected to roll back migration 2, got %v: %v", done, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO items (name) VALUES ('one')"); err == nil {
+		t.Fatal("expected the name column to be dropped")
+	}
+
+	if done, err = m.Down(ctx, 5); err != nil || len(done) != 1 {
+		t.Fatalf("expected to roll back migration 1, got %v: %v", done, err)
+	}
+
+	if got := applied(t, m); len(got) != 0 {
+		t.Fatalf("expected no applied migrations, got %v", got)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	fsys := testMigrations()
+	fsys["migrations/0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE broken (id INTEGER);\nSELECT * FROM missing;")}
+
+	db := openTest(t)
+	m := newTestMigrator(t, db, fsys)
+
+	done, err := m.Up(context.Background())
+	if err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	if len(done) != 2 {
+		t.Errorf("expected the migrations before the broken one to be applied, got %v", done)
+	}
+
+	if _, err := db.Exec("SELECT * FROM broken"); err == nil {
+		t.Error("expected the broken migration to be rolled back")
+	}
+}
+
+func TestMigrateChecksum(t *testing.T) {
+	for _, file := range []string{"migrations/0001_create_items.up.sql", "migrations/0001_create_items.down.sql"} {
+		t.Run(file, func(t *testing.T) {
+			ctx := context.Background()
+			db := openTest(t)
+
+			fsys := testMigrations()
+			delete(fsys, "migrations/0002_add_name.up.sql")
+			delete(fsys, "migrations/0002_add_name.down.sql")
+
+			if _, err := newTestMigrator(t, db, fsys).Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			fsys = testMigrations()
+			fsys[file] = &fstest.MapFile{Data: append(fsys[file].Data, "\n-- edited"...)}
+
+			m := newTestMigrator(t, db, fsys)
+
+			if _, err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("expected a checksum mismatch, got %v", err)
+			}
+
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !statuses[0].Changed || statuses[1].Applied {
+				t.Errorf("expected the edited migration to be changed and nothing else applied, got %+v", statuses)
+			}
+		})
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	var out bytes.Buffer
+
+	if _, err := newTestMigrator(t, db, testMigrations(), WithDryRun(&out)).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "-- 0001_create_items up\nCREATE TABLE items (id INTEGER PRIMARY KEY);\nCREATE TABLE tags (name TEXT);\n" +
+		"-- 0002_add_name up\nALTER TABLE items ADD COLUMN name TEXT;\n"
+
+	if out.String() != want {
+		t.Errorf("unexpected dry run output:\n%s", out.String())
+	}
+
+	var tables int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("expected a dry run not to create tables, got %d: %v", tables, err)
+	}
+}
+
+func TestMigrateTable(t *testing.T) {
+	db := openTest(t)
+
+	if _, err := NewMigrator(db, testMigrations(), WithMigrationsTable("bad; DROP TABLE items")); err == nil {
+		t.Fatal("expected an invalid table name to fail")
+	}
+
+	if _, err := newTestMigrator(t, db, testMigrations(), WithMigrationsTable("app_migrations")).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM app_migrations").Scan(&n); err != nil || n != 2 {
+		t.Errorf("expected 2 tracked migrations, got %d: %v", n, err)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	fsys := testMigrations()
+	delete(fsys, "migrations/0002_add_name.down.sql")
+
+	m := newTestMigrator(t, db, fsys)
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+
+		err := m.Command(ctx, args, &out)
+
+		return out.String(), err
+	}
+
+	if out, err := run("up", "-dry-run", "1"); err != nil || !strings.HasPrefix(out, "-- 0001_create_items up\n") || strings.Contains(out, "0002") {
+		t.Errorf("unexpected dry run: %q %v", out, err)
+	}
+
+	if out, err := run("up"); err != nil || out != "applied 0001_create_items\napplied 0002_add_name\n" {
+		t.Errorf("unexpected up: %q %v", out, err)
+	}
+
+	out, err := run("status")
+	if lines := strings.Split(out, "\n"); err != nil || len(lines) != 4 || strings.Fields(lines[2])[0] != "0002_add_name" || strings.Fields(lines[2])[1] != "applied" {
+		t.Errorf("unexpected status: %q %v", out, err)
+	}
+
+	if out, err := run("down"); err == nil || out != "" {
+		t.Errorf("expected rolling back a migration without down file to fail without output, got %q %v", out, err)
+	}
+
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "zero"}, {"up", "1", "2"}} {
+		if _, err := run(args...); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package golain
 
 import (
-	"embed"
+	"context"
+	"io/fs"
+	"net/http"
 
 	"github.com/imdario/mergo"
 	"github.com/khvh/golain/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/swaggest/openapi-go/openapi3"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // AppRouterOptions ...
@@ -15,6 +20,10 @@ type AppRouterOptions struct {
 	Port          int
 	Banner        bool
 	RequestLogger bool
+	DocsPath      string
+	DisableDocs   bool
+	MetricsPath   string
+	Monitor       queue.MonitorOptions
 }
 
 // AppRouter ...
@@ -22,18 +31,25 @@ type AppRouter interface {
 	Use(fn func(r *AppRouter)) AppRouter
 	WithDefaultMiddleware() AppRouter
 	WithRoute(method, path string, fn []HandlerFunc) AppRouter
-	WithTracing(url ...string) AppRouter
-	WithMetrics() AppRouter
-	WithFrontend(data embed.FS) AppRouter
-	WithQueue(url, pw string, opts queue.Queues, fn func(q *queue.Queue)) AppRouter
-	Run()
+	WithTracing() AppRouter
+	WithMetrics(mp metric.MeterProvider, reg *prometheus.Registry) AppRouter
+	WithFrontend(data fs.FS, opts ...FrontendOptions) AppRouter
+	WithQueue(q *queue.Queue) AppRouter
+	WithHandler(path string, h http.Handler) AppRouter
+	WithRateLimit(l *RateLimiter) AppRouter
+	WithContext(fn func(ctx context.Context) context.Context) AppRouter
+	CORS() *CORS
+	Reflector() *openapi3.Reflector
+	Options() *AppRouterOptions
+	Run() error
+	Shutdown(ctx context.Context) error
 }
 
 func mergeOptions(opts ...AppRouterOptions) *AppRouterOptions {
 	o := &AppRouterOptions{}
 
 	for _, opt := range opts {
-		_ = mergo.Merge(o, &opt, mergo.WithSliceDeepCopy)
+		_ = mergo.Merge(o, &opt)
 	}
 
 	return o
@@ -0,0 +1,318 @@
+package golain
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khvh/golain/validate"
+)
+
+// Binding sources, also used as struct tag names
+const (
+	SourcePath   = "path"
+	SourceQuery  = "query"
+	SourceHeader = "header"
+	SourceBody   = "body"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	textType     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	timeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+	}
+)
+
+// BindError is returned when a request value can't be decoded into the target type
+type BindError struct {
+	Source string
+	Field  string
+	Value  string
+	Err    error
+}
+
+// Error implements error
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid %s: %s", e.Source, e.Err)
+	}
+
+	return fmt.Sprintf("invalid %s parameter %q: %s", e.Source, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying conversion error
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Params decodes path parameters from Ctx into P using `path` struct tags and validates the result
+func Params[P any](c *Ctx) (P, error) {
+	var p P
+
+	if err := bindValues(&p, SourcePath, lookupExact(c.Params)); err != nil {
+		return p, err
+	}
+
+	return p, validate.Struct(p)
+}
+
+// Query decodes query parameters from Ctx into Q using `query` struct tags and validates the result
+func Query[Q any](c *Ctx) (Q, error) {
+	var q Q
+
+	if err := bindValues(&q, SourceQuery, lookupExact(c.Query)); err != nil {
+		return q, err
+	}
+
+	return q, validate.Struct(q)
+}
+
+// Body decodes the JSON body from Ctx into B and validates the result, an empty body leaves B at its zero value
+func Body[B any](c *Ctx) (B, error) {
+	var b B
+
+	if len(c.Body) > 0 {
+		if err := decodeBody(c.Body, &b); err != nil {
+			return b, err
+		}
+	}
+
+	return b, validate.Struct(b)
+}
+
+// Headers decodes request headers from Ctx into H using `header` struct tags and validates the result
+func Headers[H any](c *Ctx) (H, error) {
+	var h H
+
+	if err := bindValues(&h, SourceHeader, lookupHeader(c.Headers)); err != nil {
+		return h, err
+	}
+
+	return h, validate.Struct(h)
+}
+
+func decodeBody(body []byte, target any) error {
+	if err := json.Unmarshal(body, target); err != nil {
+		be := &BindError{Source: SourceBody, Err: err}
+
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			be.Field = te.Field
+			be.Value = te.Value
+		}
+
+		return be
+	}
+
+	return nil
+}
+
+type lookupFunc func(key string) (string, bool)
+
+func lookupExact(values map[string]string) lookupFunc {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+
+		return v, ok
+	}
+}
+
+func lookupHeader(values map[string]string) lookupFunc {
+	return func(key string) (string, bool) {
+		if v, ok := values[key]; ok {
+			return v, true
+		}
+
+		if v, ok := values[http.CanonicalHeaderKey(key)]; ok {
+			return v, true
+		}
+
+		for k, v := range values {
+			if strings.EqualFold(k, key) {
+				return v, true
+			}
+		}
+
+		return "", false
+	}
+}
+
+func bindValues(target any, source string, lookup lookupFunc) error {
+	v := reflect.ValueOf(target).Elem()
+
+	if v.Kind() != reflect.Struct {
+		return &BindError{Source: source, Err: fmt.Errorf("unsupported target type %s", v.Type())}
+	}
+
+	return bindStruct(v, source, lookup)
+}
+
+func bindStruct(v reflect.Value, source string, lookup lookupFunc) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, ok := tagName(field, source)
+
+		if !ok || !field.IsExported() {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := bindStruct(v.Field(i), source, lookup); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+
+		if err := setValue(v.Field(i), raw); err != nil {
+			return &BindError{Source: source, Field: name, Value: raw, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func tagName(field reflect.StructField, source string) (string, bool) {
+	tag, ok := field.Tag.Lookup(source)
+	if !ok {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+
+	if name == "-" {
+		return "", false
+	}
+
+	if name == "" {
+		name = field.Name
+	}
+
+	return name, true
+}
+
+// setValue converts raw into the type of v, slices are read as comma separated lists
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return setValue(v.Elem(), raw)
+	}
+
+	if v.Type() == timeType {
+		t, err := parseTime(raw)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(t))
+
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	if reflect.PtrTo(v.Type()).Implements(textType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+	case reflect.Slice:
+		if raw == "" {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+
+			return nil
+		}
+
+		parts := strings.Split(raw, ",")
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+
+		for i, part := range parts {
+			if err := setValue(s.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+
+		v.Set(s)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func parseTime(raw string) (time.Time, error) {
+	var err error
+
+	for _, layout := range timeLayouts {
+		var t time.Time
+
+		if t, err = time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+
+	if unix, uerr := strconv.ParseInt(raw, 10, 64); uerr == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+
+	return time.Time{}, err
+}
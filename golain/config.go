@@ -0,0 +1,311 @@
+package golain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/khvh/golain/config"
+	"github.com/khvh/golain/database"
+	"github.com/khvh/golain/logger"
+	"github.com/khvh/golain/queue"
+	"github.com/khvh/golain/telemetry"
+	"github.com/khvh/golain/validate"
+)
+
+// Backend selects the HTTP server an app runs on
+type Backend string
+
+// Supported backends
+const (
+	BackendEcho   Backend = "echo"
+	BackendFiber  Backend = "fiber"
+	BackendStdlib Backend = "stdlib"
+)
+
+// Config describes a complete app, FromConfig builds it. It can be loaded from files, env vars and flags with
+// LoadConfig and kept up to date with config.Watch and WatchConfig.
+type Config struct {
+	ID                       string        `default:"golain" desc:"service name, used in the API docs, traces and metrics"`
+	Version                  string        `desc:"service version"`
+	Environment              string        `desc:"deployment environment, e.g. production"`
+	Backend                  Backend       `default:"echo" validate:"enum=echo|fiber|stdlib" desc:"HTTP server, echo, fiber or stdlib"`
+	Host                     string        `desc:"address to listen on, all addresses by default"`
+	Port                     int           `default:"8080" validate:"min=1,max=65535" desc:"port to listen on"`
+	Banner                   bool          `desc:"print the startup banner"`
+	RequestLogger            bool          `desc:"log every request at trace level"`
+	DisableDefaultMiddleware bool          `desc:"skip the request ID, CORS and recover middleware"`
+	ShutdownTimeout          time.Duration `default:"15s" desc:"time in-flight work gets on shutdown"`
+	Log                      LogConfig
+	CORS                     CORSConfig
+	RateLimit                RateLimit
+	Docs                     DocsConfig
+	Metrics                  MetricsConfig
+	Tracing                  TracingConfig
+	Database                 database.Config
+	Migrations               MigrationsConfig
+	Queue                    QueueConfig
+	Frontend                 FrontendConfig
+}
+
+// LogConfig configures logging, see logger.Config
+type LogConfig struct {
+	Level      string            `default:"info" desc:"log level"`
+	Format     logger.Format     `default:"json" validate:"enum=json|console" desc:"json or console"`
+	Output     string            `default:"stderr" desc:"stderr, stdout or a file path"`
+	Components map[string]string `desc:"levels of components, e.g. asynq=warn"`
+}
+
+// CORSConfig lists the origins allowed to make cross-origin requests, all origins by default
+type CORSConfig struct {
+	Origins []string `desc:"allowed origins, all by default"`
+}
+
+// DocsConfig configures the API docs
+type DocsConfig struct {
+	Path    string `default:"/docs" desc:"mount point of the API docs"`
+	Disable bool   `desc:"don't serve the API docs"`
+}
+
+// MetricsConfig configures metrics, they are served at Path and optionally pushed over OTLP
+type MetricsConfig struct {
+	Disable  bool               `desc:"don't record or serve metrics"`
+	Path     string             `default:"/metrics" desc:"mount point of the Prometheus metrics"`
+	Exporter telemetry.Exporter `validate:"omitempty,enum=none|otlp-http|otlp-grpc" desc:"push metrics with otlp-http or otlp-grpc"`
+	Endpoint string             `desc:"OTLP endpoint"`
+	Insecure bool               `desc:"disable TLS for the OTLP endpoint"`
+	Headers  map[string]string  `secret:"true" desc:"headers sent to the OTLP endpoint"`
+	Interval time.Duration      `desc:"push interval"`
+}
+
+// TracingConfig configures tracing, see telemetry.Config
+type TracingConfig struct {
+	Enable      bool               `desc:"trace requests and tasks"`
+	Exporter    telemetry.Exporter `default:"otlp-http" validate:"enum=none|otlp-http|otlp-grpc|stdout|file" desc:"otlp-http, otlp-grpc, stdout, file or none"`
+	Endpoint    string             `desc:"OTLP endpoint or the file path of the file exporter"`
+	Insecure    bool               `desc:"disable TLS for the OTLP endpoint"`
+	Headers     map[string]string  `secret:"true" desc:"headers sent to the OTLP endpoint"`
+	SampleRatio float64            `default:"1" validate:"min=0,max=1" desc:"share of new traces that are sampled"`
+}
+
+// MigrationsConfig configures the migrations given with WithMigrations
+type MigrationsConfig struct {
+	OnStart bool   `desc:"apply pending migrations before the server starts"`
+	Table   string `desc:"table tracking the applied migrations, schema_migrations by default"`
+}
+
+// QueueConfig configures the queue, it is enabled by URL
+type QueueConfig struct {
+	URL         string         `desc:"Redis URL or memory://, see queue.ParseRedisURL"`
+	Password    string         `desc:"Redis password, overrides the one in the URL"`
+	Concurrency int            `validate:"min=0" desc:"tasks processed at once"`
+	Weights     map[string]int `default:"critical=6,default=3,low=1" desc:"queues and their priorities"`
+	Monitor     QueueMonitorConfig
+}
+
+// QueueMonitorConfig protects the task monitor, see queue.MonitorOptions
+type QueueMonitorConfig struct {
+	ReadOnly          bool              `desc:"allow only reading the monitor"`
+	Users             map[string]string `secret:"true" desc:"basic auth users and passwords"`
+	Tokens            []string          `desc:"bearer tokens"`
+	JWTSecret         string            `desc:"secret of HS256 bearer JWTs"`
+	AllowIPs          []string          `desc:"allowed client IPs and CIDRs"`
+	TrustForwardedFor bool              `desc:"take the client IP from X-Forwarded-For"`
+}
+
+// FrontendConfig serves a single page app from Dir or the file system given with WithFrontendFS
+type FrontendConfig struct {
+	Dir     string   `desc:"directory of the single page app"`
+	Path    string   `default:"/" desc:"mount point of the single page app"`
+	Root    string   `desc:"directory holding the build output within Dir"`
+	Index   string   `default:"index.html" desc:"file served for unknown paths"`
+	Exclude []string `desc:"path prefixes never served by the frontend"`
+}
+
+// LoadConfig loads a Config, see the config package for where values come from
+func LoadConfig(opts ...config.Option) (*Config, error) {
+	return config.Load[Config](opts...)
+}
+
+// Settings returns the parts of c that can change while the app runs, e.g. for WatchConfig
+func (c *Config) Settings() Settings {
+	return Settings{
+		Log:         logger.Levels{Level: c.Log.Level, Components: c.Log.Components},
+		CORSOrigins: c.CORS.Origins,
+		RateLimit:   c.RateLimit,
+		SampleRatio: c.Tracing.SampleRatio,
+	}
+}
+
+// WithQueueHandlers sets the func that registers task handlers when FromConfig enables the queue
+func WithQueueHandlers(fn func(q *queue.Queue)) Option {
+	return func(g *Golain) error {
+		g.queueHandlers = fn
+
+		return nil
+	}
+}
+
+// WithFrontendFS sets the file system FromConfig serves the frontend from when no directory is configured,
+// e.g. an embed.FS
+func WithFrontendFS(fsys fs.FS) Option {
+	return func(g *Golain) error {
+		g.frontendFS = fsys
+
+		return nil
+	}
+}
+
+// FromConfig builds an app as described by cfg, fields left zero take their default. Logging is set up
+// first, then the middleware, rate limiting, metrics, tracing, the database and its migrations, the queue and the frontend. opts are applied
+// after the ones taken from cfg, routes can be registered on the returned app before it runs.
+func FromConfig(cfg *Config, opts ...Option) (*Golain, error) {
+	c := *cfg
+
+	if err := config.Defaults(&c); err != nil {
+		return nil, err
+	}
+
+	if err := validate.Struct(&c); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	var backend func(port int, opts ...AppRouterOptions) Option
+
+	switch c.Backend {
+	case BackendEcho:
+		backend = WithEcho
+	case BackendFiber:
+		backend = WithFiber
+	case BackendStdlib:
+		backend = WithStdlib
+	}
+
+	closeLog, err := logger.New(logger.Config{
+		Level:      c.Log.Level,
+		Format:     c.Log.Format,
+		Output:     c.Log.Output,
+		Components: c.Log.Components,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	router := backend(c.Port, AppRouterOptions{
+		ID:            c.ID,
+		Version:       c.Version,
+		Host:          c.Host,
+		Banner:        !c.Banner,
+		RequestLogger: c.RequestLogger,
+		DocsPath:      c.Docs.Path,
+		DisableDocs:   c.Docs.Disable,
+		MetricsPath:   c.Metrics.Path,
+		Monitor: queue.MonitorOptions{
+			ReadOnly:          c.Queue.Monitor.ReadOnly,
+			Users:             c.Queue.Monitor.Users,
+			Tokens:            c.Queue.Monitor.Tokens,
+			JWTSecret:         c.Queue.Monitor.JWTSecret,
+			AllowIPs:          c.Queue.Monitor.AllowIPs,
+			TrustForwardedFor: c.Queue.Monitor.TrustForwardedFor,
+		},
+	})
+
+	g := New(append([]Option{
+		router,
+		WithShutdownTimeout(c.ShutdownTimeout),
+		WithQueueConcurrency(c.Queue.Concurrency),
+	}, opts...)...)
+
+	g.OnStop(func(ctx context.Context) error {
+		return closeLog()
+	})
+
+	// fail stops the telemetry, database and log set up so far
+	fail := func(err error) (*Golain, error) {
+		return nil, errors.Join(err, g.shutdownWithTimeout())
+	}
+
+	// checked before the database is opened, EnableQueue only logs an invalid URL
+	if c.Queue.URL != "" {
+		if _, err := queue.ParseRedisURL(c.Queue.URL); err != nil {
+			return fail(fmt.Errorf("queue: %w", err))
+		}
+	}
+
+	if !c.DisableDefaultMiddleware {
+		g.WithDefaultMiddleware()
+	}
+
+	g.CORS().SetOrigins(c.CORS.Origins...)
+
+	// installed even without a limit, so one can be set while the app runs
+	g.EnableRateLimit(c.RateLimit)
+
+	if !c.Metrics.Disable {
+		g.EnableMetricsWithConfig(telemetry.MetricsConfig{
+			Environment: c.Environment,
+			Exporter:    c.Metrics.Exporter,
+			Endpoint:    c.Metrics.Endpoint,
+			Insecure:    c.Metrics.Insecure,
+			Headers:     c.Metrics.Headers,
+			Interval:    c.Metrics.Interval,
+		})
+	}
+
+	if c.Tracing.Enable {
+		g.EnableTracingWithConfig(telemetry.Config{
+			Environment: c.Environment,
+			Exporter:    c.Tracing.Exporter,
+			Endpoint:    c.Tracing.Endpoint,
+			Insecure:    c.Tracing.Insecure,
+			Headers:     c.Tracing.Headers,
+			SampleRatio: c.Tracing.SampleRatio,
+		})
+	}
+
+	if c.Migrations.Table != "" {
+		g.migrateOpts = append(g.migrateOpts, database.WithMigrationsTable(c.Migrations.Table))
+	}
+
+	if c.Database.DSN != "" {
+		if g.EnableDatabase(c.Database); g.DB() == nil {
+			return fail(fmt.Errorf("database %s is not available", c.Database.Driver))
+		}
+	}
+
+	if c.Migrations.OnStart {
+		if g.Migrator() == nil {
+			return fail(g.noMigrator())
+		}
+
+		g.MigrateOnStart()
+	}
+
+	if c.Queue.URL != "" {
+		handlers := g.queueHandlers
+		if handlers == nil {
+			handlers = func(q *queue.Queue) {}
+		}
+
+		g.EnableQueue(c.Queue.URL, c.Queue.Password, queue.Queues(c.Queue.Weights), handlers)
+	}
+
+	fsys := g.frontendFS
+	if c.Frontend.Dir != "" {
+		fsys = os.DirFS(c.Frontend.Dir)
+	}
+
+	if fsys != nil {
+		g.EnableFrontend(fsys, FrontendOptions{
+			Path:    c.Frontend.Path,
+			Root:    c.Frontend.Root,
+			Index:   c.Frontend.Index,
+			Exclude: c.Frontend.Exclude,
+		})
+	}
+
+	return g, nil
+}
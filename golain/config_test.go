@@ -0,0 +1,23 @@
+package golain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/khvh/golain/golain"
+)
+
+func TestFromConfigInvalidQueueURL(t *testing.T) {
+	cfg := &golain.Config{Port: freePort(t)}
+	cfg.Metrics.Disable = true
+	cfg.Queue.URL = "redis://:hunter2@localhost:6379/x"
+
+	g, err := golain.FromConfig(cfg)
+	if err == nil || g != nil {
+		t.Fatal("expected FromConfig to fail for an invalid queue URL")
+	}
+
+	if !strings.Contains(err.Error(), "invalid redis database") || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package golain_test
+
+import (
+	"testing"
+
+	"github.com/khvh/golain/golain"
+	"github.com/khvh/golain/golaintest"
+)
+
+func TestConformanceEcho(t *testing.T) {
+	golaintest.RunConformance(t, golain.WithEcho)
+}
+
+func TestConformanceFiber(t *testing.T) {
+	golaintest.RunConformance(t, golain.WithFiber)
+}
+
+func TestConformanceStdlib(t *testing.T) {
+	golaintest.RunConformance(t, golain.WithStdlib)
+}
@@ -0,0 +1,67 @@
+package golain
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// corsMethods are the methods allowed in preflight responses
+const corsMethods = "GET,HEAD,PUT,PATCH,POST,DELETE"
+
+// CORS holds the origins allowed to make cross-origin requests, they can be changed while the app runs.
+// No origins or "*" allows every origin.
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+// NewCORS allows cross-origin requests from origins
+func NewCORS(origins ...string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins...)
+
+	return c
+}
+
+// SetOrigins replaces the allowed origins
+func (c *CORS) SetOrigins(origins ...string) {
+	allowed := make([]string, 0, len(origins))
+
+	for _, o := range origins {
+		if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+			allowed = append(allowed, o)
+		}
+	}
+
+	c.origins.Store(&allowed)
+}
+
+// Origins returns the allowed origins
+func (c *CORS) Origins() []string {
+	return append([]string{}, *c.origins.Load()...)
+}
+
+// Allowed reports whether requests from origin are allowed
+func (c *CORS) Allowed(origin string) bool {
+	return c.allowOrigin(origin) != ""
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin header for origin, empty when it is not allowed
+func (c *CORS) allowOrigin(origin string) string {
+	origins := *c.origins.Load()
+
+	if len(origins) == 0 {
+		return "*"
+	}
+
+	for _, o := range origins {
+		if o == "*" {
+			return "*"
+		}
+
+		if origin != "" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
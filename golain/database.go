@@ -0,0 +1,68 @@
+package golain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khvh/golain/database"
+	"github.com/rs/zerolog/log"
+)
+
+// EnableDatabase opens the database described by cfg and waits until it answers, Run fails when it can't be
+// opened. Handlers reach it through Ctx.DB, it is checked by the readiness endpoint and closed after the
+// server stopped. Query metrics are recorded with the app metrics unless cfg has a meter provider. The
+// migrations given with WithMigrations are loaded, see MigrateOnStart and Command.
+func (g *Golain) EnableDatabase(cfg database.Config) *Golain {
+	if g.db != nil {
+		log.Error().Msg("Database is already enabled")
+
+		return g
+	}
+
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = g.meters()
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		log.Err(err).Msg("Database is disabled")
+
+		return g.OnStart(failed(fmt.Errorf("open database: %w", err)))
+	}
+
+	g.db = db
+
+	if g.migrations != nil {
+		if g.migrator, err = database.NewMigrator(db, g.migrations, g.migrateOpts...); err != nil {
+			log.Err(err).Msg("Migrations are disabled")
+
+			g.migratorErr = fmt.Errorf("load migrations: %w", err)
+		}
+	}
+
+	g.r.WithContext(func(ctx context.Context) context.Context {
+		return database.NewContext(ctx, db)
+	})
+
+	g.AddReadinessCheck("database", db.Ready)
+
+	g.OnStop(func(ctx context.Context) error {
+		return db.Close()
+	})
+
+	return g
+}
+
+// DB returns the database of the app, nil until EnableDatabase is called
+func (g *Golain) DB() *database.DB {
+	return g.db
+}
+
+// DB returns the database of the app, nil when it has none
+func (c *Ctx) DB() *database.DB {
+	if c.Context == nil {
+		return nil
+	}
+
+	return database.FromContext(c.Context)
+}
@@ -0,0 +1,203 @@
+package golain_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khvh/golain/database"
+	"github.com/khvh/golain/golain"
+	"github.com/khvh/golain/golaintest"
+)
+
+func TestDatabase(t *testing.T) {
+	backends := map[string]golaintest.Factory{
+		"echo":   golain.WithEcho,
+		"fiber":  golain.WithFiber,
+		"stdlib": golain.WithStdlib,
+	}
+
+	for name, backend := range backends {
+		backend := backend
+
+		t.Run(name, func(t *testing.T) {
+			testDatabase(t, backend, false)
+		})
+
+		t.Run(name+"/routes first", func(t *testing.T) {
+			testDatabase(t, backend, true)
+		})
+	}
+}
+
+// testDatabase checks handlers reach the database, routesFirst registers them before the database is enabled
+func testDatabase(t *testing.T, backend golaintest.Factory, routesFirst bool) {
+	port := freePort(t)
+
+	var db *database.DB
+
+	routes := golain.NewRouter().Register(
+		golain.Get[string]("/items", func(c *golain.Ctx) *golain.Res {
+			if c.DB() != db {
+				return c.JSON("other database", http.StatusInternalServerError)
+			}
+
+			var name string
+
+			if err := c.DB().QueryRowContext(c.Context, "SELECT name FROM items").Scan(&name); err != nil {
+				return c.JSON(err.Error(), http.StatusInternalServerError)
+			}
+
+			return c.JSON(name)
+		}),
+	)
+
+	g := golain.New(backend(port, golain.AppRouterOptions{ID: fmt.Sprintf("database_%d", port), Banner: true, DisableDocs: true}))
+
+	if routesFirst {
+		g.RegisterRouter(routes)
+	}
+
+	g.EnableDatabase(database.Config{Driver: database.DriverSQLite, DSN: filepath.Join(t.TempDir(), "test.db"), RetryTimeout: -1})
+
+	if !routesFirst {
+		g.RegisterRouter(routes)
+	}
+
+	db = g.DB()
+	if db == nil {
+		t.Fatal("database is not enabled")
+	}
+
+	if _, err := db.Exec("CREATE TABLE items (name TEXT); INSERT INTO items (name) VALUES ('one')"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- g.Run()
+	}()
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		g.Shutdown(ctx)
+		<-done
+	}()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	waitForServer(t, port)
+
+	if code, body := get(t, base+"/items"); code != http.StatusOK || body != `"one"` {
+		t.Errorf("expected the handler to read the database, got %d %s", code, body)
+	}
+
+	code, body := get(t, base+"/health/ready")
+	if code != http.StatusOK {
+		t.Fatalf("expected ready, got %d %s", code, body)
+	}
+
+	var report struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	if err := json.Unmarshal([]byte(body), &report); err != nil || report.Checks["database"] != "ok" {
+		t.Errorf("expected the database check to pass, got %s", body)
+	}
+
+	db.Close()
+
+	if code, body := get(t, base+"/health/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready after the database closed, got %d %s", code, body)
+	}
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, strings.TrimSpace(string(body))
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitForServer(t *testing.T, port int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("server did not start on port %d", port)
+}
+
+func TestDatabaseUnavailable(t *testing.T) {
+	port := freePort(t)
+
+	g := golain.New(golain.WithStdlib(port, golain.AppRouterOptions{ID: fmt.Sprintf("database_%d", port), Banner: true, DisableDocs: true})).
+		EnableDatabase(database.Config{Driver: database.DriverSQLite, DSN: filepath.Join(t.TempDir(), "missing", "test.db"), RetryTimeout: -1})
+
+	if g.DB() != nil {
+		t.Fatal("expected no database")
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- g.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "open database") {
+			t.Fatalf("expected Run to fail opening the database, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		g.Shutdown(context.Background())
+		t.Fatal("Run did not fail")
+	}
+}
@@ -0,0 +1,67 @@
+package golain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/swaggest/openapi-go/openapi3"
+	"github.com/swaggest/swgui/v5emb"
+)
+
+const defaultDocsPath = "/docs"
+
+// docsPath returns the mount point for API docs, empty when docs are disabled
+func (o *AppRouterOptions) docsPath() string {
+	if o.DisableDocs {
+		return ""
+	}
+
+	if o.DocsPath == "" {
+		return defaultDocsPath
+	}
+
+	return "/" + strings.Trim(o.DocsPath, "/")
+}
+
+func docsURL(host string, opts *AppRouterOptions) string {
+	base := opts.docsPath()
+
+	if base == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("http://%s:%d%s", host, opts.Port, base)
+}
+
+// newDocsHandler serves the spec of ref as openapi.json and openapi.yaml under base,
+// along with an embedded Swagger UI that works without network access
+func newDocsHandler(title, base string, ref *openapi3.Reflector) http.Handler {
+	ui := v5emb.New(title, base+"/openapi.json", base+"/")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, base) {
+		case "/openapi.json":
+			data, err := json.MarshalIndent(ref.Spec, "", "  ")
+			writeSpec(w, "application/json", data, err)
+		case "/openapi.yaml":
+			data, err := ref.Spec.MarshalYAML()
+			writeSpec(w, "application/yaml", data, err)
+		default:
+			ui.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeSpec(w http.ResponseWriter, contentType string, data []byte, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	_, _ = w.Write(data)
+}
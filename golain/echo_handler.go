@@ -1,20 +1,22 @@
 package golain
 
 import (
-	"embed"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"strings"
 
 	"github.com/khvh/golain/queue"
-	"github.com/khvh/golain/telemetry"
-	"github.com/labstack/echo-contrib/prometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/swaggest/openapi-go/openapi3"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // EchoRouter ...
@@ -23,12 +25,18 @@ type EchoRouter struct {
 	opts   *AppRouterOptions
 	ref    *openapi3.Reflector
 	router *Router
+	cors   *CORS
 }
 
 func newEchoRouter(opts *AppRouterOptions) AppRouter {
 	r := &EchoRouter{
 		app:    echo.New(),
 		router: NewRouter(),
+		cors:   NewCORS(),
+		ref: InitReflector(opts.Port, addresses(), &OASOptions{
+			Title:   opts.ID,
+			Version: opts.Version,
+		}),
 	}
 
 	r.app.HideBanner = opts.Banner
@@ -36,6 +44,17 @@ func newEchoRouter(opts *AppRouterOptions) AppRouter {
 
 	r.opts = opts
 
+	if opts.RequestLogger {
+		r.WithRequestLogger()
+	}
+
+	if base := opts.docsPath(); base != "" {
+		docs := echo.WrapHandler(newDocsHandler(opts.ID, base, r.ref))
+
+		r.app.GET(base, docs)
+		r.app.GET(base+"/*", docs)
+	}
+
 	return r
 }
 
@@ -43,7 +62,6 @@ func mapEchoCtxToGolainCtx(c echo.Context) *Ctx {
 	headers := map[string]string{}
 	params := map[string]string{}
 	query := map[string]string{}
-	bts := []byte{}
 
 	for k := range c.Request().Header {
 		headers[k] = c.Request().Header.Get(k)
@@ -53,30 +71,37 @@ func mapEchoCtxToGolainCtx(c echo.Context) *Ctx {
 		params[k] = c.Param(k)
 	}
 
-	for k := range c.QueryParams() {
-		query[k] = c.QueryParam(k)
+	for k, v := range c.QueryParams() {
+		query[k] = strings.Join(v, ",")
 	}
 
-	switch c.Request().Method {
-	case http.MethodPost:
-	case http.MethodPatch:
-	case http.MethodPut:
-		_, err := c.Request().Body.Read(bts)
-		if err != nil {
-			log.Trace().Err(err).Send()
-		}
+	bts, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Trace().Err(err).Send()
+	}
+
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
 	}
 
 	return NewCtx().
+		SetRequest(c.Request().Method, c.Request().URL.Path).
 		SetHeaders(headers).
 		SetParams(params).
 		SetQuery(query).
-		SetBody(bts)
+		SetBody(bts).
+		SetContext(c.Request().Context()).
+		SetLogger(requestLogger(c.Request().Context(), requestID, c.Path(), c.Request().Method))
 }
 
-func mapGolainHandlerToEchoHandler(handler HandlerFunc) echo.HandlerFunc {
+func mapGolainHandlerToEchoHandler(handlers []HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		res := handler(mapEchoCtxToGolainCtx(c))
+		res := handle(mapEchoCtxToGolainCtx(c), handlers)
+
+		if res.code == http.StatusNoContent {
+			return c.NoContent(res.code)
+		}
 
 		return c.JSON(res.code, res.data)
 	}
@@ -90,12 +115,53 @@ func (f *EchoRouter) Use(fn func(r *AppRouter)) AppRouter {
 // WithDefaultMiddleware ...
 func (f *EchoRouter) WithDefaultMiddleware() AppRouter {
 	f.app.Use(middleware.RequestID())
-	f.app.Use(middleware.CORS())
+	f.app.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return f.cors.Allowed(origin), nil
+		},
+	}))
 	f.app.Use(middleware.Recover())
 
 	return f
 }
 
+// CORS returns the origins the default middleware allows
+func (f *EchoRouter) CORS() *CORS {
+	return f.cors
+}
+
+// WithRateLimit rejects requests of clients over the limit of l with 429
+func (f *EchoRouter) WithRateLimit(l *RateLimiter) AppRouter {
+	f.app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if ok, wait := l.Allow(remoteIP(c.Request().RemoteAddr)); !ok {
+				retryAfter, problem := rateLimited(wait)
+
+				c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter)
+
+				return c.JSON(http.StatusTooManyRequests, problem)
+			}
+
+			return next(c)
+		}
+	})
+
+	return f
+}
+
+// WithContext derives the context of every request with fn
+func (f *EchoRouter) WithContext(fn func(ctx context.Context) context.Context) AppRouter {
+	f.app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(c.Request().WithContext(fn(c.Request().Context())))
+
+			return next(c)
+		}
+	})
+
+	return f
+}
+
 // WithRequestLogger ...
 func (f *EchoRouter) WithRequestLogger() AppRouter {
 	f.app.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
@@ -116,87 +182,138 @@ func (f *EchoRouter) WithRequestLogger() AppRouter {
 	return f
 }
 
-// WithTracing ...
-func (f *EchoRouter) WithTracing(url ...string) AppRouter {
+// WithTracing adds a server span to every request
+func (f *EchoRouter) WithTracing() AppRouter {
 	id := strings.ReplaceAll(f.opts.ID, "-", "_")
-	u := "http://localhost:14268/api/traces"
 
-	otel.Tracer(id)
+	f.app.Use(otelecho.Middleware(id))
 
-	if len(url) > 0 {
-		u = url[0]
-	}
+	return f
+}
 
-	telemetry.New(id, u)
+// WithFrontend serves a single page app from data, API routes registered on the router take precedence
+func (f *EchoRouter) WithFrontend(data fs.FS, opts ...FrontendOptions) AppRouter {
+	fe := newFrontend(data, f.opts, opts...)
+	handler := echo.WrapHandler(fe)
 
-	f.app.Use(otelecho.Middleware(id))
+	for _, path := range fe.routes() {
+		f.app.GET(path, handler)
+		f.app.HEAD(path, handler)
+	}
 
 	return f
 }
 
-// WithFrontend ...
-func (f *EchoRouter) WithFrontend(data embed.FS) AppRouter {
-	return f
+// Options returns the options of the router
+func (f *EchoRouter) Options() *AppRouterOptions {
+	return f.opts
 }
 
-// WithQueue ...
-func (f *EchoRouter) WithQueue(url, pw string, opts queue.Queues, fn func(q *queue.Queue)) AppRouter {
-	q, mon := queue.
-		CreateServer(url, 11, opts).
-		MountMonitor("127.0.0.1:6379", "")
+// WithQueue mounts the task monitor and dead letter API of q at /monitoring/tasks, protected by the Monitor options
+func (f *EchoRouter) WithQueue(q *queue.Queue) AppRouter {
+	_, mon := q.WithMonitor(f.opts.Monitor).MountMonitor()
 
-	f.app.Any("/monitoring/tasks/*", echo.WrapHandler(mon))
+	if mon != nil {
+		f.app.Any("/monitoring/tasks/*", echo.WrapHandler(mon))
 
-	fn(q)
+		log.Trace().Msgf("Asynq running on http://0.0.0.0:%d/monitoring/tasks", f.opts.Port)
+	}
 
-	q.Run()
+	return f
+}
 
-	log.Trace().Msgf("Asynq running on http://0.0.0.0:%d/monitoring/tasks", f.opts.Port)
+// WithHandler serves h at path for every method
+func (f *EchoRouter) WithHandler(path string, h http.Handler) AppRouter {
+	f.app.Any(path, echo.WrapHandler(h))
 
 	return f
 }
 
-// WithMetrics ...
-func (f *EchoRouter) WithMetrics() AppRouter {
-	prometheus.NewPrometheus(f.opts.ID, nil).Use(f.app)
+// WithMetrics records request metrics through mp and serves reg at /metrics
+func (f *EchoRouter) WithMetrics(mp metric.MeterProvider, reg *prometheus.Registry) AppRouter {
+	m := newHTTPMetrics(mp)
+
+	f.app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			req := m.start(r.Context(), r.Method, c.Scheme(), r.ContentLength)
+
+			err := next(c)
+			route := f.matchedRoute(c, err)
+
+			if err != nil {
+				// let the error handler write the response so the status code is known
+				c.Error(err)
+			}
+
+			req.finish(route, c.Response().Status, c.Response().Size)
+
+			return nil
+		}
+	})
+
+	f.app.GET(f.opts.metricsPath(), echo.WrapHandler(metricsHandler(reg)))
 
 	return f
 }
 
-// WithRoute ...
-func (f *EchoRouter) WithRoute(method, path string, fn []HandlerFunc) AppRouter {
-	handlers := []echo.MiddlewareFunc{}
+// matchedRoute returns the path of the route that handled c, empty when echo found no route. Echo sets the
+// request path as c.Path() then, so it is looked up among the routes when the not found handlers ran.
+func (f *EchoRouter) matchedRoute(c echo.Context, err error) string {
+	if err != echo.ErrNotFound && err != echo.ErrMethodNotAllowed {
+		return c.Path()
+	}
 
-	for i, h := range fn {
-		if i > 0 {
-			handlers = append(handlers, func(next echo.HandlerFunc) echo.HandlerFunc {
-				return mapGolainHandlerToEchoHandler(h)
-			})
+	for _, r := range f.app.Routes() {
+		if r.Path == c.Path() && r.Method == c.Request().Method {
+			return c.Path()
 		}
 	}
 
-	switch method {
-	case http.MethodGet:
-		f.app.GET(path, mapGolainHandlerToEchoHandler(fn[0]), handlers...)
+	return ""
+}
+
+// WithRoute ...
+func (f *EchoRouter) WithRoute(method, path string, fn []HandlerFunc) AppRouter {
+	handler := mapGolainHandlerToEchoHandler(fn)
+
+	if method == MethodAny {
+		f.app.Any(path, handler)
+	} else {
+		f.app.Add(method, path, handler)
 	}
 
 	return f
 }
 
-// Run ...
-func (f *EchoRouter) Run() {
+// Reflector returns the OpenAPI reflector routes are documented in
+func (f *EchoRouter) Reflector() *openapi3.Reflector {
+	return f.ref
+}
+
+// Run starts the server and blocks until it fails or is shut down
+func (f *EchoRouter) Run() error {
 	for _, host := range addresses() {
 		log.
 			Info().
 			Str("id", f.opts.ID).
 			Str("URL", fmt.Sprintf("http://%s:%d", host, f.opts.Port)).
-			Str("OpenAPI", fmt.Sprintf("http://%s:%d/docs", host, f.opts.Port)).
+			Str("OpenAPI", docsURL(host, f.opts)).
 			Send()
 	}
 
 	log.Info().Msgf("%s started with Echo 🚀", f.opts.ID)
 
-	f.app.Start(fmt.Sprintf("%s:%d", f.opts.Host, f.opts.Port))
+	if err := f.app.Start(fmt.Sprintf("%s:%d", f.opts.Host, f.opts.Port)); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done
+func (f *EchoRouter) Shutdown(ctx context.Context) error {
+	return f.app.Shutdown(ctx)
 }
 
 // WithEcho ...
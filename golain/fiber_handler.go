@@ -1,62 +1,116 @@
 package golain
 
 import (
-	"embed"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
-	"strings"
+	"time"
 
-	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/contrib/otelfiber"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/monitor"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/khvh/golain/queue"
 	"github.com/khvh/golain/router"
-	"github.com/khvh/golain/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/swaggest/openapi-go/openapi3"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // FiberRouter ...
 type FiberRouter struct {
-	app  *fiber.App
-	opts *AppRouterOptions
-	ref  *openapi3.Reflector
+	app      *fiber.App
+	opts     *AppRouterOptions
+	ref      *openapi3.Reflector
+	frontend *frontend
+	cors     *CORS
+	contexts []func(ctx context.Context) context.Context
 }
 
 func newFiberRouter(opts *AppRouterOptions) AppRouter {
 	r := &FiberRouter{
-		app: fiber.New(fiber.Config{DisableStartupMessage: opts.Banner}),
+		app:  fiber.New(fiber.Config{DisableStartupMessage: opts.Banner}),
+		cors: NewCORS(),
 		ref: router.InitReflector(opts.Port, addresses(), &router.OASOptions{
-			Title: opts.ID,
+			Title:   opts.ID,
+			Version: opts.Version,
 		}),
 	}
 
 	r.opts = opts
 
+	if opts.RequestLogger {
+		r.WithRequestLogger()
+	}
+
+	if base := opts.docsPath(); base != "" {
+		docs := adaptor.HTTPHandler(newDocsHandler(opts.ID, base, r.ref))
+
+		r.app.Get(base, docs)
+		r.app.Get(base+"/*", docs)
+	}
+
 	return r
 }
 
 func mapFiberCtxToGolainCtx(c *fiber.Ctx) *Ctx {
 	q := map[string]string{}
 
-	c.QueryParser(&q)
+	c.Context().QueryArgs().VisitAll(func(key, val []byte) {
+		if v, ok := q[string(key)]; ok {
+			q[string(key)] = v + "," + string(val)
+		} else {
+			q[string(key)] = string(val)
+		}
+	})
+
+	requestID := c.GetRespHeader(fiber.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Get(fiber.HeaderXRequestID)
+	}
+
+	// fiber's strings point into buffers fasthttp reuses for later requests, the Ctx may outlive this one
+	method := utils.CopyString(c.Method())
 
 	return NewCtx().
-		SetHeaders(c.GetReqHeaders()).
-		SetParams(c.AllParams()).
+		SetRequest(method, string(c.Request().URI().Path())).
+		SetHeaders(copyValues(c.GetReqHeaders())).
+		SetParams(copyValues(c.AllParams())).
 		SetQuery(q).
-		SetBody(c.Body())
+		SetBody(append([]byte{}, c.Body()...)).
+		SetContext(c.UserContext()).
+		SetLogger(requestLogger(c.UserContext(), utils.CopyString(requestID), c.Route().Path, method))
 }
 
-func mapGolainHandlerToFiber(handler HandlerFunc) fiber.Handler {
+// copyValues replaces the values of m by copies that don't share memory with fasthttp
+func copyValues(m map[string]string) map[string]string {
+	for k, v := range m {
+		m[k] = utils.CopyString(v)
+	}
+
+	return m
+}
+
+// mapGolainHandlerToFiber derives the request context with the funcs added by WithContext before calling
+// handlers, fiber middleware only runs for routes added after it
+func (f *FiberRouter) mapGolainHandlerToFiber(handlers []HandlerFunc) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		return c.JSON(handler(mapFiberCtxToGolainCtx(c)).data)
+		for _, fn := range f.contexts {
+			c.SetUserContext(fn(c.UserContext()))
+		}
+
+		res := handle(mapFiberCtxToGolainCtx(c), handlers)
+
+		if res.code == http.StatusNoContent {
+			return c.SendStatus(res.code)
+		}
+
+		return c.Status(res.code).JSON(res.data)
 	}
 }
 
@@ -69,99 +123,213 @@ func (f *FiberRouter) Use(fn func(r *AppRouter)) AppRouter {
 func (f *FiberRouter) WithDefaultMiddleware() AppRouter {
 	f.app.Use(requestid.New())
 	f.app.Use(recover.New())
-	f.app.Use(cors.New())
-	f.app.Get("/monitor", monitor.New(monitor.Config{Title: f.opts.ID}))
+	f.app.Use(f.corsMiddleware)
 
 	return f
 }
 
-// WithRequestLogger ...
-func (f *FiberRouter) WithRequestLogger() AppRouter {
+// corsMiddleware allows the origins of f.cors, fiber's cors middleware can't change them at runtime
+func (f *FiberRouter) corsMiddleware(c *fiber.Ctx) error {
+	c.Vary(fiber.HeaderOrigin)
+
+	allow := f.cors.allowOrigin(c.Get(fiber.HeaderOrigin))
+	if allow != "" {
+		c.Set(fiber.HeaderAccessControlAllowOrigin, allow)
+	}
+
+	if c.Method() != fiber.MethodOptions || c.Get(fiber.HeaderAccessControlRequestMethod) == "" {
+		return c.Next()
+	}
+
+	if allow != "" {
+		c.Set(fiber.HeaderAccessControlAllowMethods, corsMethods)
+
+		if h := c.Get(fiber.HeaderAccessControlRequestHeaders); h != "" {
+			c.Set(fiber.HeaderAccessControlAllowHeaders, h)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CORS returns the origins the default middleware allows
+func (f *FiberRouter) CORS() *CORS {
+	return f.cors
+}
+
+// WithRateLimit rejects requests of clients over the limit of l with 429
+func (f *FiberRouter) WithRateLimit(l *RateLimiter) AppRouter {
+	f.app.Use(func(c *fiber.Ctx) error {
+		// the limiter keeps the key beyond the request
+		if ok, wait := l.Allow(utils.CopyString(c.IP())); !ok {
+			retryAfter, problem := rateLimited(wait)
+
+			c.Set(fiber.HeaderRetryAfter, retryAfter)
+
+			return c.Status(fiber.StatusTooManyRequests).JSON(problem)
+		}
+
+		return c.Next()
+	})
+
 	return f
 }
 
-// WithTracing ...
-func (f *FiberRouter) WithTracing(url ...string) AppRouter {
-	id := strings.ReplaceAll(f.opts.ID, "-", "_")
-	u := "http://localhost:14268/api/traces"
+// WithContext derives the context of every request with fn, also for routes added before
+func (f *FiberRouter) WithContext(fn func(ctx context.Context) context.Context) AppRouter {
+	f.contexts = append(f.contexts, fn)
 
-	otel.Tracer(id)
+	return f
+}
 
-	if len(url) > 0 {
-		u = url[0]
-	}
+// WithRequestLogger ...
+func (f *FiberRouter) WithRequestLogger() AppRouter {
+	f.app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		log.Trace().
+			Str("method", c.Method()).
+			Int("code", c.Response().StatusCode()).
+			Str("uri", c.OriginalURL()).
+			Str("from", c.Context().RemoteAddr().String()).
+			Send()
 
-	telemetry.New(id, u)
+		return err
+	})
 
+	return f
+}
+
+// WithTracing adds a server span to every request
+func (f *FiberRouter) WithTracing() AppRouter {
 	f.app.Use(otelfiber.Middleware(otelfiber.WithServerName(f.opts.ID)))
 
 	return f
 }
 
-// WithFrontend ...
-func (f *FiberRouter) WithFrontend(data embed.FS) AppRouter {
+// WithFrontend serves a single page app from data, API routes registered on the router take precedence
+func (f *FiberRouter) WithFrontend(data fs.FS, opts ...FrontendOptions) AppRouter {
+	f.frontend = newFrontend(data, f.opts, opts...)
+
 	return f
 }
 
-// WithQueue ...
-func (f *FiberRouter) WithQueue(url, pw string, opts queue.Queues, fn func(q *queue.Queue)) AppRouter {
-	q, mon := queue.
-		CreateServer(url, 11, opts).
-		MountMonitor(url, pw)
+// Options returns the options of the router
+func (f *FiberRouter) Options() *AppRouterOptions {
+	return f.opts
+}
 
-	f.app.All("/monitoring/tasks/*", adaptor.HTTPHandler(mon))
+// WithQueue mounts the task monitor and dead letter API of q at /monitoring/tasks, protected by the Monitor options
+func (f *FiberRouter) WithQueue(q *queue.Queue) AppRouter {
+	_, mon := q.WithMonitor(f.opts.Monitor).MountMonitor()
 
-	fn(q)
+	if mon != nil {
+		f.app.All("/monitoring/tasks/*", adaptor.HTTPHandler(mon))
 
-	q.Run()
+		log.Trace().Msgf("Asynq running on http://0.0.0.0:%d/monitoring/tasks", f.opts.Port)
+	}
+
+	return f
+}
 
-	log.Trace().Msgf("Asynq running on http://0.0.0.0:%d/monitoring/tasks", f.opts.Port)
+// WithHandler serves h at path for every method
+func (f *FiberRouter) WithHandler(path string, h http.Handler) AppRouter {
+	f.app.All(path, adaptor.HTTPHandler(h))
 
 	return f
 }
 
-// WithMetrics ...
-func (f *FiberRouter) WithMetrics() AppRouter {
-	id := strings.ReplaceAll(f.opts.ID, "-", "_")
-	prometheus := fiberprometheus.New(id)
+// WithMetrics records request metrics through mp and serves reg at /metrics
+func (f *FiberRouter) WithMetrics(mp metric.MeterProvider, reg *prometheus.Registry) AppRouter {
+	m := newHTTPMetrics(mp)
+
+	f.app.Use(func(c *fiber.Ctx) error {
+		// the attributes outlive the request, fiber reuses the memory of its strings
+		req := m.start(c.UserContext(), utils.CopyString(c.Method()), utils.CopyString(c.Protocol()), int64(len(c.Request().Body())))
+
+		err := c.Next()
+		route := matchedRoute(c, err)
+
+		if err != nil {
+			// let the error handler write the response so the status code is known
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		req.finish(route, c.Response().StatusCode(), int64(len(c.Response().Body())))
 
-	prometheus.RegisterAt(f.app, "/metrics")
+		return nil
+	})
 
-	f.app.Use(prometheus.Middleware)
+	f.app.Get(f.opts.metricsPath(), adaptor.HTTPHandler(metricsHandler(reg)))
 
 	return f
 }
 
-// WithRoute ...
-func (f *FiberRouter) WithRoute(method, path string, fn []HandlerFunc) AppRouter {
-	handlers := []fiber.Handler{}
+// matchedRoute returns the path of the route that handled c, empty when fiber found no route. Fiber leaves the
+// last matched middleware in c.Route() then, so the error it returns for unmatched requests is checked instead.
+func matchedRoute(c *fiber.Ctx, err error) string {
+	var fe *fiber.Error
 
-	for _, h := range fn {
-		handlers = append(handlers, mapGolainHandlerToFiber(h))
+	if errors.As(err, &fe) && (fe == fiber.ErrMethodNotAllowed ||
+		fe.Code == fiber.StatusNotFound && fe.Message == "Cannot "+c.Method()+" "+string(c.Request().URI().PathOriginal())) {
+		return ""
 	}
 
-	switch method {
-	case http.MethodGet:
-		f.app.Get(path, handlers...)
+	return c.Route().Path
+}
+
+// WithRoute ...
+func (f *FiberRouter) WithRoute(method, path string, fn []HandlerFunc) AppRouter {
+	handler := f.mapGolainHandlerToFiber(fn)
+
+	if method == MethodAny {
+		f.app.All(path, handler)
+	} else {
+		f.app.Add(method, path, handler)
 	}
 
 	return f
 }
 
-// Run ...
-func (f *FiberRouter) Run() {
+// Reflector returns the OpenAPI reflector routes are documented in
+func (f *FiberRouter) Reflector() *openapi3.Reflector {
+	return f.ref
+}
+
+// Run starts the server and blocks until it fails or is shut down
+func (f *FiberRouter) Run() error {
 	for _, host := range addresses() {
 		log.
 			Info().
 			Str("id", f.opts.ID).
 			Str("URL", fmt.Sprintf("http://%s:%d", host, f.opts.Port)).
-			Str("OpenAPI", fmt.Sprintf("http://%s:%d/docs", host, f.opts.Port)).
+			Str("OpenAPI", docsURL(host, f.opts)).
 			Send()
 	}
 
+	// fiber matches routes in registration order, the frontend catch-all goes last
+	if f.frontend != nil {
+		handler := adaptor.HTTPHandler(f.frontend)
+
+		for _, path := range f.frontend.routes() {
+			f.app.Get(path, handler)
+		}
+	}
+
 	log.Info().Msgf("%s started with Fiber 🚀", f.opts.ID)
 
-	f.app.Listen(fmt.Sprintf("%s:%d", f.opts.Host, f.opts.Port))
+	return f.app.Listen(fmt.Sprintf("%s:%d", f.opts.Host, f.opts.Port))
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done
+func (f *FiberRouter) Shutdown(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		return f.app.ShutdownWithTimeout(time.Until(deadline))
+	}
+
+	return f.app.Shutdown()
 }
 
 // WithFiber ...
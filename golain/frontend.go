@@ -0,0 +1,239 @@
+package golain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+var (
+	defaultFrontendExcludes = []string{"/api", "/metrics", "/monitoring", "/health"}
+	hashedAsset             = regexp.MustCompile(`[.-]([0-9A-Za-z_]{8,})\.[0-9a-z]+$`)
+	encodings               = []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+)
+
+// FrontendOptions configures how WithFrontend serves a single page app
+type FrontendOptions struct {
+	// Path is the mount point, defaults to /
+	Path string
+	// Root is the directory in the file system holding the build output, e.g. dist
+	Root string
+	// Index is served for paths that don't match a file, defaults to index.html
+	Index string
+	// Exclude lists path prefixes that are never served by the frontend,
+	// /api, /metrics, /monitoring, /health and the docs and metrics paths are always excluded
+	Exclude []string
+}
+
+// frontend serves files from an fs.FS with SPA fallback, precompressed variants and ETags
+type frontend struct {
+	fsys    fs.FS
+	mount   string
+	index   string
+	exclude []string
+	etags   sync.Map
+}
+
+type etag struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+func newFrontend(fsys fs.FS, opts *AppRouterOptions, fo ...FrontendOptions) *frontend {
+	o := FrontendOptions{}
+
+	if len(fo) > 0 {
+		o = fo[0]
+	}
+
+	if o.Root != "" {
+		sub, err := fs.Sub(fsys, strings.Trim(o.Root, "/"))
+		if err != nil {
+			log.Err(err).Send()
+		} else {
+			fsys = sub
+		}
+	}
+
+	f := &frontend{
+		fsys:  fsys,
+		mount: "/" + strings.Trim(o.Path, "/"),
+		index: o.Index,
+	}
+
+	if f.index == "" {
+		f.index = "index.html"
+	}
+
+	f.exclude = append(f.exclude, defaultFrontendExcludes...)
+	f.exclude = append(f.exclude, o.Exclude...)
+
+	if docs := opts.docsPath(); docs != "" {
+		f.exclude = append(f.exclude, docs)
+	}
+
+	if metrics := opts.metricsPath(); metrics != defaultMetricsPath {
+		f.exclude = append(f.exclude, metrics)
+	}
+
+	return f
+}
+
+// routes returns the path patterns the frontend is mounted at in Echo and Fiber
+func (f *frontend) routes() []string {
+	if f.mount == "/" {
+		return []string{"/*"}
+	}
+
+	return []string{f.mount, f.mount + "/*"}
+}
+
+// matches reports whether p is below the mount point and not excluded
+func (f *frontend) matches(p string) bool {
+	if !hasPathPrefix(p, f.mount) {
+		return false
+	}
+
+	for _, prefix := range f.exclude {
+		if hasPathPrefix(p, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServeHTTP implements http.Handler
+func (f *frontend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if !f.matches(r.URL.Path) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, f.mount)), "/")
+
+	if name == "" || name == "." {
+		name = f.index
+	}
+
+	if info, err := fs.Stat(f.fsys, name); err != nil || info.IsDir() {
+		// missing assets are 404s, everything else is a client side route
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		name = f.index
+	}
+
+	f.serveFile(w, r, name)
+}
+
+func (f *frontend) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	file := name
+	accept := r.Header.Get("Accept-Encoding")
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	for _, enc := range encodings {
+		if !strings.Contains(accept, enc.name) {
+			continue
+		}
+
+		if info, err := fs.Stat(f.fsys, name+enc.ext); err == nil && !info.IsDir() {
+			file = name + enc.ext
+			w.Header().Set("Content-Encoding", enc.name)
+
+			break
+		}
+	}
+
+	data, err := fs.ReadFile(f.fsys, file)
+	if err != nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else {
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+	}
+
+	if name != f.index && isHashed(name) {
+		w.Header().Set("Cache-Control", cacheImmutable)
+	} else {
+		w.Header().Set("Cache-Control", cacheRevalidate)
+	}
+
+	w.Header().Set("ETag", f.etag(file, data))
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// etag returns a strong ETag for the contents of file, cached until its size or modification time changes
+func (f *frontend) etag(file string, data []byte) string {
+	info, err := fs.Stat(f.fsys, file)
+	if err != nil {
+		return hashETag(data)
+	}
+
+	if cached, ok := f.etags.Load(file); ok {
+		e := cached.(*etag)
+
+		if e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+			return e.value
+		}
+	}
+
+	e := &etag{modTime: info.ModTime(), size: info.Size(), value: hashETag(data)}
+
+	f.etags.Store(file, e)
+
+	return e.value
+}
+
+// isHashed reports whether name looks like a bundler output with a content hash, e.g. app.3f9a1c2b.js or index-B7x2k9Qa.css
+func isHashed(name string) bool {
+	m := hashedAsset.FindStringSubmatch(path.Base(name))
+
+	return m != nil && strings.ContainsAny(m[1], "0123456789")
+}
+
+func hashETag(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func hasPathPrefix(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
@@ -1,16 +1,46 @@
 package golain
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
 	"net"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/khvh/golain/database"
 	"github.com/khvh/golain/queue"
+	"github.com/khvh/golain/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
 // Golain ...
 type Golain struct {
-	r AppRouter
+	r                AppRouter
+	registry         *prometheus.Registry
+	queue            *queue.Queue
+	queueConcurrency int
+	tracing          telemetry.ShutdownFunc
+	meterProvider    *sdkmetric.MeterProvider
+	limiter          *RateLimiter
+	queueHandlers    func(q *queue.Queue)
+	frontendFS       fs.FS
+	db               *database.DB
+	migrations       fs.FS
+	migrateOpts      []database.MigrateOption
+	migrator         *database.Migrator
+	migratorErr      error
+	readiness        *readiness
+	onStart          []Hook
+	onStop           []Hook
+	shutdownTimeout  time.Duration
+	shutdown         sync.Once
+	shutdownErr      error
+	stopped          chan struct{}
 }
 
 // Option represents option function
@@ -18,7 +48,11 @@ type Option func(g *Golain) error
 
 // New ...
 func New(opts ...Option) *Golain {
-	instance := &Golain{}
+	instance := &Golain{
+		registry:        prometheus.NewRegistry(),
+		shutdownTimeout: defaultShutdownTimeout,
+		stopped:         make(chan struct{}),
+	}
 
 	for _, opt := range opts {
 		if err := opt(instance); err != nil {
@@ -29,6 +63,20 @@ func New(opts ...Option) *Golain {
 	return instance
 }
 
+// WithQueueConcurrency sets how many tasks the queue processes at once, by default the number of CPUs
+// with Redis and 10 with the memory backend
+func WithQueueConcurrency(n int) Option {
+	return func(g *Golain) error {
+		if n < 0 {
+			return fmt.Errorf("invalid queue concurrency %d", n)
+		}
+
+		g.queueConcurrency = n
+
+		return nil
+	}
+}
+
 // Register ...
 func (g *Golain) Register(fn func(g *Golain)) *Golain {
 	fn(g)
@@ -36,35 +84,191 @@ func (g *Golain) Register(fn func(g *Golain)) *Golain {
 	return g
 }
 
-// RegisterRoutes ...
+// RegisterRoutes registers routes with the app router and adds them to the OpenAPI spec
 func (g *Golain) RegisterRoutes(routes ...*Route) *Golain {
 	for _, r := range routes {
+		r.spec.Build(g.r.Reflector())
+
 		g.r.WithRoute(r.method, r.path, r.handlers)
 	}
 
 	return g
 }
 
-// EnableMetrics ...
+// RegisterRouter registers the routes of one or more routers under their prefix and group
+func (g *Golain) RegisterRouter(routers ...*Router) *Golain {
+	for _, r := range routers {
+		g.RegisterRoutes(r.Routes()...)
+	}
+
+	return g
+}
+
+// EnableMetrics records HTTP server metrics and serves the metrics of the app at /metrics
 func (g *Golain) EnableMetrics() *Golain {
-	g.r.WithMetrics()
+	return g.EnableMetricsWithConfig(telemetry.MetricsConfig{})
+}
+
+// EnableMetricsWithConfig sets up metrics as described by cfg and records HTTP server metrics following the
+// OpenTelemetry semantic conventions. The service name and version default to the ID and version of the app and
+// the registerer to Registry, which is served at /metrics. It has to be called before EnableQueue and Meter.
+func (g *Golain) EnableMetricsWithConfig(cfg telemetry.MetricsConfig) *Golain {
+	if g.meterProvider != nil {
+		log.Error().Msg("Metrics were set up by the queue or a meter, the metrics config is ignored")
+	} else if err := g.setupMetrics(cfg); err != nil {
+		log.Err(err).Msg("Metrics are disabled")
+
+		return g
+	}
+
+	g.r.WithMetrics(g.meterProvider, g.registry)
 
 	return g
 }
 
-// EnableTracing ...
+// Meter returns a meter named name for metrics of the application, they are served with the app metrics
+func (g *Golain) Meter(name string) *telemetry.Meter {
+	return telemetry.NewMeter(g.meters(), name)
+}
+
+// meters returns the meter provider of the app, one exposing metrics on Registry is set up on first use
+func (g *Golain) meters() metric.MeterProvider {
+	if g.meterProvider == nil {
+		if err := g.setupMetrics(telemetry.MetricsConfig{}); err != nil {
+			log.Err(err).Send()
+
+			return metric.NewNoopMeterProvider()
+		}
+	}
+
+	return g.meterProvider
+}
+
+func (g *Golain) setupMetrics(cfg telemetry.MetricsConfig) error {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = g.r.Options().ID
+	}
+
+	if cfg.Version == "" {
+		cfg.Version = g.r.Options().Version
+	}
+
+	if cfg.Registerer == nil {
+		cfg.Registerer = g.registry
+	}
+
+	mp, err := telemetry.NewMeterProvider(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	g.meterProvider = mp
+
+	return nil
+}
+
+// Registry returns the Prometheus registry of the app, collectors registered on it are served at /metrics
+func (g *Golain) Registry() *prometheus.Registry {
+	return g.registry
+}
+
+// EnableTracing exports traces over OTLP HTTP to url, http://localhost:4318 by default
 func (g *Golain) EnableTracing(url ...string) *Golain {
-	g.r.WithTracing(url...)
+	cfg := telemetry.Config{Exporter: telemetry.ExporterOTLPHTTP}
+
+	if len(url) > 0 {
+		cfg.Endpoint = url[0]
+	}
+
+	return g.EnableTracingWithConfig(cfg)
+}
+
+// EnableTracingWithConfig sets up tracing as described by cfg and adds a server span to every request,
+// the service name and version default to the ID and version of the app
+func (g *Golain) EnableTracingWithConfig(cfg telemetry.Config) *Golain {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = g.r.Options().ID
+	}
+
+	if cfg.Version == "" {
+		cfg.Version = g.r.Options().Version
+	}
+
+	shutdown, err := telemetry.New(context.Background(), cfg)
+	if err != nil {
+		log.Err(err).Msg("Tracing is disabled")
+
+		return g
+	}
+
+	g.tracing = shutdown
+
+	g.r.WithTracing()
+
+	return g
+}
+
+// EnableFrontend serves a single page app from data, see FrontendOptions
+func (g *Golain) EnableFrontend(data fs.FS, opts ...FrontendOptions) *Golain {
+	g.r.WithFrontend(data, opts...)
+
 	return g
 }
 
-// EnableQueue ...
+// EnableQueue enables the queue at url, see queue.ParseRedisURL for supported URLs. A non-empty pw overrides
+// the password in url, memory:// runs tasks in-process.
 func (g *Golain) EnableQueue(url, pw string, opts queue.Queues, fn func(q *queue.Queue)) *Golain {
-	g.r.WithQueue(url, pw, opts, fn)
+	cfg, err := queue.ParseRedisURL(url)
+	if err != nil {
+		log.Err(err).Send()
+
+		return g
+	}
+
+	if pw != "" {
+		cfg.Password = pw
+	}
+
+	return g.EnableQueueWithConfig(cfg, opts, fn)
+}
+
+// EnableQueueWithConfig enables the queue described by cfg, fn registers handlers and sets queue options
+// before the monitor is mounted and the queue starts
+func (g *Golain) EnableQueueWithConfig(cfg queue.RedisConfig, opts queue.Queues, fn func(q *queue.Queue)) *Golain {
+	if g.queue != nil {
+		log.Error().Msg("Queue is already enabled")
+
+		return g
+	}
+
+	g.queue = queue.
+		CreateServer(cfg, g.queueConcurrency, opts).
+		WithName(g.r.Options().ID).
+		WithMetrics(g.meters())
+
+	fn(g.queue)
+
+	g.r.WithQueue(g.queue)
+
+	g.queue.Run()
 
 	return g
 }
 
+// Queue returns the queue of the app, nil until EnableQueue is called
+func (g *Golain) Queue() *queue.Queue {
+	return g.queue
+}
+
+// Client returns a client adding tasks to the queue of the app, nil until EnableQueue is called
+func (g *Golain) Client() *queue.Client {
+	if g.queue == nil {
+		return nil
+	}
+
+	return g.queue.Client()
+}
+
 // WithDefaultMiddleware ...
 func (g *Golain) WithDefaultMiddleware() *Golain {
 	g.r.WithDefaultMiddleware()
@@ -72,11 +276,6 @@ func (g *Golain) WithDefaultMiddleware() *Golain {
 	return g
 }
 
-// Run ...
-func (g *Golain) Run() {
-	g.r.Run()
-}
-
 // Addresses returns addresses the server can bind to
 func addresses() []string {
 	host, _ := os.Hostname()
@@ -0,0 +1,100 @@
+package golain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	readinessPath    = "/health/ready"
+	readinessTimeout = 5 * time.Second
+)
+
+// CheckFunc reports whether a dependency of the app can be used
+type CheckFunc func(ctx context.Context) error
+
+// readiness runs the checks registered with AddReadinessCheck
+type readiness struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]CheckFunc
+}
+
+// readinessReport is the body of readiness responses, checks map names to "ok" or the error
+type readinessReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// ServeHTTP runs the checks concurrently and responds with 200 when all pass and 503 otherwise
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	rd.mu.RLock()
+	names := append([]string{}, rd.names...)
+	checks := make([]CheckFunc, len(names))
+
+	for i, name := range names {
+		checks[i] = rd.checks[name]
+	}
+
+	rd.mu.RUnlock()
+
+	results := make([]error, len(names))
+	wg := sync.WaitGroup{}
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+
+			results[i] = check(ctx)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	report := readinessReport{Status: "ok", Checks: map[string]string{}}
+	code := http.StatusOK
+
+	for i, name := range names {
+		report.Checks[name] = "ok"
+
+		if results[i] != nil {
+			report.Status = "unavailable"
+			report.Checks[name] = results[i].Error()
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// AddReadinessCheck adds a check to the readiness endpoint at /health/ready, which responds with 503 while
+// any check fails. The endpoint is mounted with the first check.
+func (g *Golain) AddReadinessCheck(name string, check CheckFunc) *Golain {
+	if g.readiness == nil {
+		g.readiness = &readiness{checks: map[string]CheckFunc{}}
+
+		g.r.WithHandler(readinessPath, g.readiness)
+	}
+
+	g.readiness.mu.Lock()
+	defer g.readiness.mu.Unlock()
+
+	if _, ok := g.readiness.checks[name]; !ok {
+		g.readiness.names = append(g.readiness.names, name)
+	}
+
+	g.readiness.checks[name] = check
+
+	return g
+}
@@ -0,0 +1,121 @@
+package golain
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultShutdownTimeout = 15 * time.Second
+
+// Hook is a function run when the app starts or stops
+type Hook func(ctx context.Context) error
+
+// WithShutdownTimeout sets how long Run waits for in-flight requests, queue workers and stop hooks after SIGINT or SIGTERM
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(g *Golain) error {
+		g.shutdownTimeout = d
+
+		return nil
+	}
+}
+
+// OnStart registers hooks that run in order before the server starts listening, an error aborts Run and
+// shuts the app down
+func (g *Golain) OnStart(hooks ...Hook) *Golain {
+	g.onStart = append(g.onStart, hooks...)
+
+	return g
+}
+
+// failed returns a start hook failing with err, it reports setup errors of the builder chain from Run
+func failed(err error) Hook {
+	return func(ctx context.Context) error {
+		return err
+	}
+}
+
+// OnStop registers hooks that run in reverse order during Shutdown, after the server has drained
+func (g *Golain) OnStop(hooks ...Hook) *Golain {
+	g.onStop = append(g.onStop, hooks...)
+
+	return g
+}
+
+// Run starts the app and blocks until the server fails, Shutdown is called or
+// the process receives SIGINT or SIGTERM, in which case the app is shut down gracefully
+func (g *Golain) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for _, hook := range g.onStart {
+		if err := hook(ctx); err != nil {
+			return errors.Join(err, g.shutdownWithTimeout())
+		}
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- g.r.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return errors.Join(err, g.shutdownWithTimeout())
+		}
+
+		<-g.stopped
+
+		return g.shutdownErr
+	case <-ctx.Done():
+		log.Info().Msg("Shutting down")
+
+		err := g.shutdownWithTimeout()
+
+		return errors.Join(err, <-done)
+	}
+}
+
+func (g *Golain) shutdownWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout)
+	defer cancel()
+
+	return g.Shutdown(ctx)
+}
+
+// Shutdown stops the server from accepting new requests and waits for in-flight ones,
+// stops the queue, runs stop hooks and flushes traces and metrics. Work still running when ctx is
+// done is abandoned. Calling Shutdown more than once returns the result of the first call.
+func (g *Golain) Shutdown(ctx context.Context) error {
+	g.shutdown.Do(func() {
+		defer close(g.stopped)
+
+		errs := []error{g.r.Shutdown(ctx)}
+
+		if g.queue != nil {
+			g.queue.Shutdown()
+		}
+
+		for i := len(g.onStop) - 1; i >= 0; i-- {
+			errs = append(errs, g.onStop[i](ctx))
+		}
+
+		if g.tracing != nil {
+			errs = append(errs, g.tracing(ctx))
+		}
+
+		if g.meterProvider != nil {
+			errs = append(errs, g.meterProvider.Shutdown(ctx))
+		}
+
+		g.shutdownErr = errors.Join(errs...)
+	})
+
+	return g.shutdownErr
+}
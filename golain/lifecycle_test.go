@@ -0,0 +1,59 @@
+package golain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/khvh/golain/golain"
+	"github.com/khvh/golain/golaintest"
+)
+
+func TestStartHookFails(t *testing.T) {
+	backends := map[string]golaintest.Factory{
+		"echo":   golain.WithEcho,
+		"fiber":  golain.WithFiber,
+		"stdlib": golain.WithStdlib,
+	}
+
+	for name, backend := range backends {
+		backend := backend
+
+		t.Run(name, func(t *testing.T) {
+			port := freePort(t)
+			failure := errors.New("start failed")
+			stopped := false
+
+			g := golain.New(backend(port, golain.AppRouterOptions{ID: fmt.Sprintf("lifecycle_%d", port), Banner: true, DisableDocs: true})).
+				OnStart(func(ctx context.Context) error {
+					return failure
+				}).
+				OnStop(func(ctx context.Context) error {
+					stopped = true
+
+					return nil
+				})
+
+			done := make(chan error, 1)
+
+			go func() {
+				done <- g.Run()
+			}()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, failure) {
+					t.Fatalf("expected the start hook error, got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not fail")
+			}
+
+			if !stopped {
+				t.Error("expected the stop hooks to run")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package golain
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/khvh/golain/logger"
+	"github.com/khvh/golain/queue"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const logLevelPath = "/monitoring/log/level"
+
+// requestLogger returns a logger for a request with its ID, the trace and span IDs of the span in ctx,
+// the route pattern and the method
+func requestLogger(ctx context.Context, requestID, route, method string) zerolog.Logger {
+	lc := logger.WithSpan(ctx, log.Logger.With()).Str("method", method)
+
+	if route != "" {
+		lc = lc.Str("route", route)
+	}
+
+	if requestID != "" {
+		lc = lc.Str("request_id", requestID)
+	}
+
+	return lc.Logger()
+}
+
+// EnableLogLevels serves the log levels at /monitoring/log/level, GET lists them and PUT changes the default
+// level or the level of a component, see logger.Handler. Requests have to pass one of auth, without it every
+// request is refused.
+func (g *Golain) EnableLogLevels(auth ...queue.Authenticator) *Golain {
+	levels := logger.Handler()
+
+	g.r.WithHandler(logLevelPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, a := range auth {
+			if a(r) {
+				levels.ServeHTTP(w, r)
+
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}))
+
+	return g
+}
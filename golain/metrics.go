@@ -0,0 +1,125 @@
+package golain
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const meterName = "github.com/khvh/golain"
+
+const defaultMetricsPath = "/metrics"
+
+// metricsPath returns the mount point for metrics
+func (o *AppRouterOptions) metricsPath() string {
+	if o.MetricsPath == "" {
+		return defaultMetricsPath
+	}
+
+	return "/" + strings.Trim(o.MetricsPath, "/")
+}
+
+// metricsHandler serves the metrics in reg along with the process wide metrics of the default registry
+func metricsHandler(reg prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, reg}, promhttp.HandlerOpts{})
+}
+
+// httpMetrics records the HTTP server metrics of the OpenTelemetry semantic conventions, every router records
+// the same instruments with the same attributes
+type httpMetrics struct {
+	duration     syncfloat64.Histogram
+	requestSize  syncint64.Histogram
+	responseSize syncint64.Histogram
+	active       syncint64.UpDownCounter
+}
+
+// httpRequest is a request being measured, finish records it
+type httpRequest struct {
+	ctx   context.Context
+	m     *httpMetrics
+	start time.Time
+	attrs []attribute.KeyValue
+	size  int64
+}
+
+func newHTTPMetrics(mp metric.MeterProvider) *httpMetrics {
+	meter := mp.Meter(meterName)
+	noop := metric.NewNoopMeter()
+	m := &httpMetrics{}
+
+	var err error
+
+	if m.duration, err = meter.SyncFloat64().Histogram("http.server.duration",
+		instrument.WithDescription("How long handling HTTP requests took"), instrument.WithUnit(unit.Milliseconds)); err != nil {
+		log.Err(err).Send()
+		m.duration, _ = noop.SyncFloat64().Histogram("http.server.duration")
+	}
+
+	if m.requestSize, err = meter.SyncInt64().Histogram("http.server.request.size",
+		instrument.WithDescription("The size of HTTP request bodies"), instrument.WithUnit(unit.Bytes)); err != nil {
+		log.Err(err).Send()
+		m.requestSize, _ = noop.SyncInt64().Histogram("http.server.request.size")
+	}
+
+	if m.responseSize, err = meter.SyncInt64().Histogram("http.server.response.size",
+		instrument.WithDescription("The size of HTTP response bodies"), instrument.WithUnit(unit.Bytes)); err != nil {
+		log.Err(err).Send()
+		m.responseSize, _ = noop.SyncInt64().Histogram("http.server.response.size")
+	}
+
+	if m.active, err = meter.SyncInt64().UpDownCounter("http.server.active_requests",
+		instrument.WithDescription("The number of HTTP requests being handled")); err != nil {
+		log.Err(err).Send()
+		m.active, _ = noop.SyncInt64().UpDownCounter("http.server.active_requests")
+	}
+
+	return m
+}
+
+// start starts measuring a request, size is the Content-Length of the request, -1 when unknown
+func (m *httpMetrics) start(ctx context.Context, method, scheme string, size int64) *httpRequest {
+	attrs := []attribute.KeyValue{semconv.HTTPMethodKey.String(method), semconv.HTTPSchemeKey.String(scheme)}
+
+	m.active.Add(ctx, 1, attrs...)
+
+	return &httpRequest{ctx: ctx, m: m, start: time.Now(), attrs: attrs, size: size}
+}
+
+// finish records a request, route is the matched route pattern and empty when no route matched
+func (r *httpRequest) finish(route string, status int, size int64) {
+	r.m.active.Add(r.ctx, -1, r.attrs...)
+
+	attrs := append(r.attrs, semconv.HTTPStatusCodeKey.Int(status))
+
+	if route != "" {
+		attrs = append(attrs, semconv.HTTPRouteKey.String(route))
+	}
+
+	r.m.duration.Record(r.ctx, float64(time.Since(r.start))/float64(time.Millisecond), attrs...)
+	r.m.responseSize.Record(r.ctx, size, attrs...)
+
+	if r.size >= 0 {
+		r.m.requestSize.Record(r.ctx, r.size, attrs...)
+	}
+}
+
+// scheme returns http or https for r
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
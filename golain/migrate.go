@@ -0,0 +1,73 @@
+package golain
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/khvh/golain/database"
+	"github.com/rs/zerolog/log"
+)
+
+// WithMigrations sets the migrations of the database, e.g. an embed.FS, see database.LoadMigrations. They are
+// loaded by EnableDatabase.
+func WithMigrations(fsys fs.FS, opts ...database.MigrateOption) Option {
+	return func(g *Golain) error {
+		g.migrations = fsys
+		g.migrateOpts = append(g.migrateOpts, opts...)
+
+		return nil
+	}
+}
+
+// Migrator returns the migrator of the database, nil without database or migrations
+func (g *Golain) Migrator() *database.Migrator {
+	return g.migrator
+}
+
+// MigrateOnStart applies the pending migrations before the server starts, Run fails when they can't be
+// loaded or applied. Replicas starting at once wait for each other.
+func (g *Golain) MigrateOnStart() *Golain {
+	if g.migrator == nil {
+		err := g.noMigrator()
+
+		log.Err(err).Msg("Migrations are disabled")
+
+		return g.OnStart(failed(err))
+	}
+
+	return g.OnStart(func(ctx context.Context) error {
+		_, err := g.migrator.Up(ctx)
+
+		return err
+	})
+}
+
+// Command runs the subcommand in args, e.g. the positional arguments of config.Loader.Args, and tells whether
+// args held one. The only subcommand is migrate, see database.Migrator.Command, e.g. migrate up -dry-run.
+// A service checks for a subcommand before Run:
+//
+//	if ok, err := g.Command(loader.Args()); ok {
+//		...
+//	}
+func (g *Golain) Command(args []string) (bool, error) {
+	if len(args) == 0 || args[0] != "migrate" {
+		return false, nil
+	}
+
+	if g.migrator == nil {
+		return true, g.noMigrator()
+	}
+
+	return true, g.migrator.Command(context.Background(), args[1:], os.Stdout)
+}
+
+// noMigrator tells why the app has no migrator
+func (g *Golain) noMigrator() error {
+	if g.migratorErr != nil {
+		return g.migratorErr
+	}
+
+	return errors.New("migrations need WithMigrations and EnableDatabase")
+}
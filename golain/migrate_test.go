@@ -0,0 +1,71 @@
+package golain_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/khvh/golain/database"
+	"github.com/khvh/golain/golain"
+)
+
+func TestMigrateOnStartFails(t *testing.T) {
+	tests := map[string]struct {
+		migrations fstest.MapFS
+		database   bool
+		err        string
+	}{
+		"no database": {
+			migrations: fstest.MapFS{"0001_x.up.sql": {Data: []byte("SELECT 1")}},
+			err:        "need WithMigrations and EnableDatabase",
+		},
+		"invalid migrations": {
+			migrations: fstest.MapFS{"0001_x.up.sql": {Data: []byte("SELECT 1")}, "0001_y.up.sql": {Data: []byte("SELECT 1")}},
+			database:   true,
+			err:        "load migrations",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			port := freePort(t)
+
+			g := golain.New(
+				golain.WithStdlib(port, golain.AppRouterOptions{ID: fmt.Sprintf("migrate_%d", port), Banner: true, DisableDocs: true}),
+				golain.WithMigrations(test.migrations),
+			)
+
+			if test.database {
+				g.EnableDatabase(database.Config{Driver: database.DriverSQLite, DSN: filepath.Join(t.TempDir(), "test.db"), RetryTimeout: -1})
+			}
+
+			g.MigrateOnStart()
+
+			if _, err := g.Command([]string{"migrate", "status"}); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected Command to fail with %q, got %v", test.err, err)
+			}
+
+			done := make(chan error, 1)
+
+			go func() {
+				done <- g.Run()
+			}()
+
+			select {
+			case err := <-done:
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected Run to fail with %q, got %v", test.err, err)
+				}
+			case <-time.After(5 * time.Second):
+				g.Shutdown(context.Background())
+				t.Fatal("Run did not fail")
+			}
+		})
+	}
+}
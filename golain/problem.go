@@ -0,0 +1,58 @@
+package golain
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/khvh/golain/oas"
+	"github.com/khvh/golain/validate"
+)
+
+// Problem codes used for request errors
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+)
+
+// Problem converts a binding or validation error into an oas.Error,
+// failed constraints are listed field by field under data.errors
+func Problem(err error) *oas.Error {
+	var (
+		be   *BindError
+		errs validate.Errors
+	)
+
+	switch {
+	case errors.As(err, &errs):
+		fields := make([]*oas.Error, len(errs))
+
+		for i, fe := range errs {
+			data := oas.JSONObject{"field": fe.Field}
+
+			if fe.Param != "" {
+				data["param"] = fe.Param
+			}
+
+			fields[i] = oas.Err(fe.Rule).Message(fe.Error()).Data(&data)
+		}
+
+		return oas.Err(CodeValidationFailed).
+			Message("request validation failed").
+			Data(&oas.JSONObject{"errors": fields})
+	case errors.As(err, &be):
+		data := oas.JSONObject{"source": be.Source}
+
+		if be.Field != "" {
+			data["field"] = be.Field
+		}
+
+		return oas.Err(CodeBadRequest).Message(be.Error()).Data(&data)
+	default:
+		return oas.Err(CodeBadRequest).Message(err.Error())
+	}
+}
+
+// BadRequest responds with 400 and the Problem for err
+func (c *Ctx) BadRequest(err error) *Res {
+	return c.JSON(Problem(err), http.StatusBadRequest)
+}
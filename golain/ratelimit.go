@@ -0,0 +1,143 @@
+package golain
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khvh/golain/oas"
+	"golang.org/x/time/rate"
+)
+
+// CodeRateLimited is the problem code of requests rejected by the rate limiter
+const CodeRateLimited = "rate_limited"
+
+const sweepInterval = time.Minute
+
+// RateLimit allows Requests per Per from each client in bursts of up to Burst requests. Per is a second and
+// Burst is Requests by default, no Requests disables rate limiting.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+func (l RateLimit) limit() (rate.Limit, int) {
+	per, burst := l.Per, l.Burst
+
+	if per <= 0 {
+		per = time.Second
+	}
+
+	if burst <= 0 {
+		burst = l.Requests
+	}
+
+	return rate.Limit(float64(l.Requests) / per.Seconds()), burst
+}
+
+// RateLimiter limits requests by client IP with a token bucket per client, the limit can be changed while
+// the app runs
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	clients map[string]*rateClient
+	swept   time.Time
+}
+
+type rateClient struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing limit
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{limit: limit, clients: map[string]*rateClient{}, swept: time.Now()}
+}
+
+// Limit returns the limit in use
+func (l *RateLimiter) Limit() RateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+// SetLimit changes the limit, clients keep the requests they have left
+func (l *RateLimiter) SetLimit(limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	r, burst := limit.limit()
+	now := time.Now()
+
+	for _, c := range l.clients {
+		c.limiter.SetLimitAt(now, r)
+		c.limiter.SetBurstAt(now, burst)
+	}
+}
+
+// Allow reports whether the client with key may make a request now and otherwise how long it has to wait
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit.Requests <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	r, burst := l.limit.limit()
+
+	if now.Sub(l.swept) > sweepInterval {
+		l.sweep(now, r, burst)
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &rateClient{limiter: rate.NewLimiter(r, burst)}
+		l.clients[key] = c
+	}
+
+	c.seen = now
+
+	res := c.limiter.ReserveN(now, 1)
+
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// sweep forgets clients whose bucket has filled up again, they start over with a full bucket anyway
+func (l *RateLimiter) sweep(now time.Time, r rate.Limit, burst int) {
+	full := time.Duration(float64(burst) / float64(r) * float64(time.Second))
+
+	for key, c := range l.clients {
+		if now.Sub(c.seen) > full {
+			delete(l.clients, key)
+		}
+	}
+
+	l.swept = now
+}
+
+// rateLimited is the Retry-After header and the body of a rejected request
+func rateLimited(wait time.Duration) (string, *oas.Error) {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds()))), oas.Err(CodeRateLimited).Message("too many requests")
+}
+
+// remoteIP returns the IP of a request's RemoteAddr, forwarded headers are ignored as clients can set them
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"path"
 	"runtime"
 	"strings"
 
+	"github.com/khvh/golain/logger"
 	"github.com/khvh/golain/oas"
+	"github.com/khvh/golain/validate"
+	"github.com/rs/zerolog"
+	"github.com/swaggest/jsonschema-go"
 	"github.com/swaggest/openapi-go/openapi3"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -15,11 +20,15 @@ import (
 
 // Ctx ...
 type Ctx struct {
+	Method  string
+	Path    string
 	Params  map[string]string
 	Query   map[string]string
 	Headers map[string]string
 	Body    []byte
 	Context context.Context
+	locals  map[string]any
+	logger  *zerolog.Logger
 }
 
 // NewCtx ...
@@ -27,6 +36,14 @@ func NewCtx() *Ctx {
 	return &Ctx{}
 }
 
+// SetRequest sets the request method and path for Ctx
+func (c *Ctx) SetRequest(method, path string) *Ctx {
+	c.Method = method
+	c.Path = path
+
+	return c
+}
+
 // SetParams is a setter for Ctx.Params
 func (c *Ctx) SetParams(p map[string]string) *Ctx {
 	c.Params = p
@@ -62,6 +79,45 @@ func (c *Ctx) SetContext(ctx context.Context) *Ctx {
 	return c
 }
 
+// SetLogger sets the logger of the request and stores it in Ctx.Context, so it is available to code that only
+// gets the context through logger.Ctx
+func (c *Ctx) SetLogger(l zerolog.Logger) *Ctx {
+	c.logger = &l
+
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+
+	c.Context = l.WithContext(c.Context)
+
+	return c
+}
+
+// Log returns the logger of the request, it carries the request ID, the trace and span IDs, the route and the method
+func (c *Ctx) Log() *zerolog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+
+	return logger.Ctx(c.Context)
+}
+
+// Set stores a value for later handlers of the same request
+func (c *Ctx) Set(key string, value any) *Ctx {
+	if c.locals == nil {
+		c.locals = map[string]any{}
+	}
+
+	c.locals[key] = value
+
+	return c
+}
+
+// Get returns a value stored with Set
+func (c *Ctx) Get(key string) any {
+	return c.locals[key]
+}
+
 // Res ...
 type Res struct {
 	data any
@@ -84,9 +140,23 @@ func (c *Ctx) JSON(data any, statusCode ...int) *Res {
 	}
 }
 
-// HandlerFunc ...
+// HandlerFunc handles a request, returning nil passes the request to the next handler of the route
 type HandlerFunc func(c *Ctx) *Res
 
+// MethodAny matches every HTTP method
+const MethodAny = "ANY"
+
+// handle runs handlers in order until one of them returns a response
+func handle(c *Ctx, handlers []HandlerFunc) *Res {
+	for _, h := range handlers {
+		if res := h(c); res != nil {
+			return res
+		}
+	}
+
+	return c.JSON(nil, http.StatusNoContent)
+}
+
 // Route ...
 type Route struct {
 	path     string
@@ -124,6 +194,8 @@ type OASOptions struct {
 func InitReflector(port int, addresses []string, opts *OASOptions) *openapi3.Reflector {
 	ref := &openapi3.Reflector{}
 
+	ref.DefaultOptions = append(ref.DefaultOptions, jsonschema.InterceptProperty(validate.InterceptProperty))
+
 	if opts.OASVersion == "" {
 		opts.OASVersion = "3.1.0"
 	}
@@ -194,6 +266,42 @@ func (r *Router) Group(name string) *Router {
 	return r
 }
 
+// Routes returns the routes with the router prefix and group applied
+func (r *Router) Routes() []*Route {
+	routes := []*Route{}
+
+	for _, route := range r.routes {
+		rt := *route
+
+		if r.prefix != "" {
+			rt.path = joinPath(r.prefix, route.path)
+			rt.spec = route.spec.Clone().AddPrefix(r.prefix)
+		}
+
+		if r.group != "" {
+			if rt.spec == route.spec {
+				rt.spec = route.spec.Clone()
+			}
+
+			rt.spec.ReplaceTags(r.group)
+		}
+
+		routes = append(routes, &rt)
+	}
+
+	return routes
+}
+
+func joinPath(prefix, p string) string {
+	joined := path.Join("/", prefix, p)
+
+	if strings.HasSuffix(p, "/") && joined != "/" {
+		joined += "/"
+	}
+
+	return joined
+}
+
 // Get creates a GET route
 func Get[T any](path string, handlers ...HandlerFunc) *Route {
 	pc, _, _, _ := runtime.Caller(1)
@@ -273,45 +381,62 @@ func Patch[T interface{}, D interface{}](path string, handlers ...HandlerFunc) *
 	}
 }
 
-func getPackage(pc uintptr) string {
-	funcName := runtime.FuncForPC(pc).Name()
-	lastSlash := strings.LastIndexByte(funcName, '/')
+// Head creates a HEAD route
+func Head[T any](path string, handlers ...HandlerFunc) *Route {
+	pc, _, _, _ := runtime.Caller(1)
 
-	if lastSlash < 0 {
-		lastSlash = 0
+	var t T
+
+	return &Route{
+		path:     path,
+		spec:     oas.Of(path, getPackage(pc)).Head(t),
+		method:   http.MethodHead,
+		handlers: handlers,
 	}
+}
 
-	lastDot := strings.LastIndexByte(funcName[lastSlash:], '.') + lastSlash
+// Options creates an OPTIONS route
+func Options[T any](path string, handlers ...HandlerFunc) *Route {
+	pc, _, _, _ := runtime.Caller(1)
 
-	caser := cases.Title(language.English)
+	var t T
 
-	return caser.String(strings.ToLower(funcName[:lastDot]))
+	return &Route{
+		path:     path,
+		spec:     oas.Of(path, getPackage(pc)).Options(t),
+		method:   http.MethodOptions,
+		handlers: handlers,
+	}
 }
 
-// Params return path parameters from Ctx
-func Params[P any](c *Ctx) P {
-	var p P
-
-	return p
-}
+// Any creates a route matching every HTTP method
+func Any[T any, D any](path string, handlers ...HandlerFunc) *Route {
+	pc, _, _, _ := runtime.Caller(1)
 
-// Query returns query parameters from Ctx
-func Query[Q any](c *Ctx) Q {
-	var q Q
+	var (
+		t T
+		d D
+	)
 
-	return q
+	return &Route{
+		path:     path,
+		spec:     oas.Of(path, getPackage(pc)).Any(t, d),
+		method:   MethodAny,
+		handlers: handlers,
+	}
 }
 
-// Body returns body from Ctx
-func Body[B any](c *Ctx) B {
-	var b B
+func getPackage(pc uintptr) string {
+	funcName := runtime.FuncForPC(pc).Name()
+	lastSlash := strings.LastIndexByte(funcName, '/')
 
-	return b
-}
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
 
-// Headers returns query headers from Ctx
-func Headers[H any](c *Ctx) H {
-	var h H
+	lastDot := strings.LastIndexByte(funcName[lastSlash:], '.') + lastSlash
 
-	return h
+	caser := cases.Title(language.English)
+
+	return caser.String(strings.ToLower(funcName[:lastDot]))
 }
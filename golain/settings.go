@@ -0,0 +1,92 @@
+package golain
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/khvh/golain/config"
+	"github.com/khvh/golain/logger"
+	"github.com/khvh/golain/telemetry"
+	"github.com/rs/zerolog/log"
+)
+
+// Settings are the parts of the app config that can change while the app runs
+type Settings struct {
+	Log         logger.Levels
+	CORSOrigins []string
+	RateLimit   RateLimit
+	SampleRatio float64
+}
+
+// Apply changes the settings of the running app. The rate limit takes effect when EnableRateLimit was
+// called and the sample ratio when tracing is enabled.
+func (g *Golain) Apply(s Settings) error {
+	return g.apply(nil, s)
+}
+
+// apply changes the settings that differ from old, all of them without old
+func (g *Golain) apply(old *Settings, s Settings) error {
+	var errs []error
+
+	if old == nil || !reflect.DeepEqual(old.Log, s.Log) {
+		if err := logger.SetLevels(s.Log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if old == nil || !reflect.DeepEqual(old.CORSOrigins, s.CORSOrigins) {
+		g.CORS().SetOrigins(s.CORSOrigins...)
+	}
+
+	if g.limiter != nil && (old == nil || old.RateLimit != s.RateLimit) {
+		g.limiter.SetLimit(s.RateLimit)
+	}
+
+	if old == nil || old.SampleRatio != s.SampleRatio {
+		telemetry.SetSampleRatio(s.SampleRatio)
+	}
+
+	return errors.Join(errs...)
+}
+
+// WatchConfig applies the settings fn takes from the config of w and applies them again whenever a reload
+// changes them. Settings changed in between, e.g. through the log level endpoint, are only overwritten when
+// their part of the config changes. The returned func stops following w.
+func WatchConfig[T any](g *Golain, w *config.Watcher[T], fn func(cfg *T) Settings) (stop func()) {
+	if err := g.Apply(fn(w.Get())); err != nil {
+		log.Err(err).Msg("Config settings were not applied")
+	}
+
+	return w.Subscribe(func(old, new *T) {
+		prev := fn(old)
+
+		if err := g.apply(&prev, fn(new)); err != nil {
+			log.Err(err).Msg("Config settings were not applied")
+		}
+	})
+}
+
+// CORS returns the origins allowed to make cross-origin requests, see WithDefaultMiddleware
+func (g *Golain) CORS() *CORS {
+	return g.r.CORS()
+}
+
+// EnableRateLimit limits requests by client IP, the limit can be changed later with RateLimiter or Apply
+func (g *Golain) EnableRateLimit(limit RateLimit) *Golain {
+	if g.limiter != nil {
+		g.limiter.SetLimit(limit)
+
+		return g
+	}
+
+	g.limiter = NewRateLimiter(limit)
+
+	g.r.WithRateLimit(g.limiter)
+
+	return g
+}
+
+// RateLimiter returns the rate limiter of the app, nil until EnableRateLimit is called
+func (g *Golain) RateLimiter() *RateLimiter {
+	return g.limiter
+}
@@ -0,0 +1,475 @@
+package golain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/khvh/golain/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/swaggest/openapi-go/openapi3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// StdlibRouter is an AppRouter built on net/http and ServeMux patterns, it can be used as an http.Handler
+type StdlibRouter struct {
+	mux      *http.ServeMux
+	handler  http.Handler
+	mw       []func(http.Handler) http.Handler
+	opts     *AppRouterOptions
+	ref      *openapi3.Reflector
+	srv      *http.Server
+	frontend *frontend
+	cors     *CORS
+}
+
+type routeKey struct{}
+
+// routeInfo is filled in by route handlers so middleware can see the matched route
+type routeInfo struct {
+	path string
+}
+
+func newStdlibRouter(opts *AppRouterOptions) AppRouter {
+	r := &StdlibRouter{
+		mux:  http.NewServeMux(),
+		opts: opts,
+		cors: NewCORS(),
+		ref: InitReflector(opts.Port, addresses(), &OASOptions{
+			Title:   opts.ID,
+			Version: opts.Version,
+		}),
+	}
+
+	r.handler = http.HandlerFunc(r.route)
+	r.srv = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", opts.Host, opts.Port),
+		Handler: r,
+	}
+
+	if base := opts.docsPath(); base != "" {
+		docs := newDocsHandler(opts.ID, base, r.ref)
+
+		r.mux.Handle("GET "+base, docs)
+		r.mux.Handle("GET "+base+"/", docs)
+	}
+
+	if opts.RequestLogger {
+		r.WithRequestLogger()
+	}
+
+	return r
+}
+
+// stdlibPattern converts an Echo/Fiber style path into a ServeMux pattern,
+// names maps pattern wildcards to the keys used in Ctx.Params
+func stdlibPattern(path string) (pattern string, names map[string]string) {
+	names = map[string]string{}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			names[segment[1:]] = segment[1:]
+			segments[i] = "{" + segment[1:] + "}"
+		case segment == "*" && i == len(segments)-1:
+			names["wildcard"] = "*"
+			segments[i] = "{wildcard...}"
+		}
+	}
+
+	pattern = "/" + strings.Join(segments, "/")
+
+	switch {
+	case pattern == "/":
+		pattern = "/{$}"
+	case strings.HasSuffix(path, "/"):
+		pattern += "/"
+	}
+
+	return pattern, names
+}
+
+func mapRequestToGolainCtx(w http.ResponseWriter, r *http.Request, route string, names map[string]string) *Ctx {
+	headers := map[string]string{}
+	params := map[string]string{}
+	query := map[string]string{}
+
+	for k := range r.Header {
+		headers[k] = r.Header.Get(k)
+	}
+
+	for name, key := range names {
+		params[key] = r.PathValue(name)
+	}
+
+	for k, v := range r.URL.Query() {
+		query[k] = strings.Join(v, ",")
+	}
+
+	bts := []byte{}
+
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Trace().Err(err).Send()
+		}
+
+		bts = b
+	}
+
+	requestID := w.Header().Get("X-Request-Id")
+	if requestID == "" {
+		requestID = r.Header.Get("X-Request-Id")
+	}
+
+	return NewCtx().
+		SetRequest(r.Method, r.URL.Path).
+		SetHeaders(headers).
+		SetParams(params).
+		SetQuery(query).
+		SetBody(bts).
+		SetContext(r.Context()).
+		SetLogger(requestLogger(r.Context(), requestID, route, r.Method))
+}
+
+func writeStdlibRes(w http.ResponseWriter, res *Res) {
+	if res.code == http.StatusNoContent {
+		w.WriteHeader(res.code)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(res.code)
+
+	if err := json.NewEncoder(w).Encode(res.data); err != nil {
+		log.Trace().Err(err).Send()
+	}
+}
+
+func mapGolainHandlerToStdlib(path string, names map[string]string, handlers []HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(routeKey{}).(*routeInfo); ok {
+			info.path = path
+		}
+
+		writeStdlibRes(w, handle(mapRequestToGolainCtx(w, r, path, names), handlers))
+	})
+}
+
+// route dispatches to the mux, requests that match no pattern fall back to the frontend
+func (f *StdlibRouter) route(w http.ResponseWriter, r *http.Request) {
+	if f.frontend != nil && f.frontend.matches(r.URL.Path) && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		if _, pattern := f.mux.Handler(r); pattern == "" {
+			f.frontend.ServeHTTP(w, r)
+
+			return
+		}
+	}
+
+	f.mux.ServeHTTP(w, r)
+}
+
+// ServeHTTP implements http.Handler
+func (f *StdlibRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.handler.ServeHTTP(w, r)
+}
+
+// UseHandler adds net/http middleware, middleware runs in the order it was added
+func (f *StdlibRouter) UseHandler(mw ...func(http.Handler) http.Handler) *StdlibRouter {
+	f.mw = append(f.mw, mw...)
+
+	var h http.Handler = http.HandlerFunc(f.route)
+
+	for i := len(f.mw) - 1; i >= 0; i-- {
+		h = f.mw[i](h)
+	}
+
+	f.handler = h
+
+	return f
+}
+
+// Use ...
+func (f *StdlibRouter) Use(fn func(r *AppRouter)) AppRouter {
+	return f
+}
+
+// WithDefaultMiddleware ...
+func (f *StdlibRouter) WithDefaultMiddleware() AppRouter {
+	f.UseHandler(requestIDMiddleware, f.corsMiddleware, recoverMiddleware)
+
+	return f
+}
+
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+
+		if id == "" {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-Id", id)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *StdlibRouter) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		allow := f.cors.allowOrigin(r.Header.Get("Origin"))
+		if allow != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allow)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allow != "" {
+				w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+
+				if h := r.Header.Get("Access-Control-Request-Headers"); h != "" {
+					w.Header().Set("Access-Control-Allow-Headers", h)
+				}
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS returns the origins the default middleware allows
+func (f *StdlibRouter) CORS() *CORS {
+	return f.cors
+}
+
+// WithRateLimit rejects requests of clients over the limit of l with 429
+func (f *StdlibRouter) WithRateLimit(l *RateLimiter) AppRouter {
+	f.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := l.Allow(remoteIP(r.RemoteAddr)); !ok {
+				retryAfter, problem := rateLimited(wait)
+
+				w.Header().Set("Retry-After", retryAfter)
+				w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+				w.WriteHeader(http.StatusTooManyRequests)
+
+				_ = json.NewEncoder(w).Encode(problem)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	return f
+}
+
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				log.Error().Interface("panic", rec).Bytes("stack", debug.Stack()).Send()
+
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusWriter records the status code and the size of the body written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+	size int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WithContext derives the context of every request with fn
+func (f *StdlibRouter) WithContext(fn func(ctx context.Context) context.Context) AppRouter {
+	f.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(fn(r.Context())))
+		})
+	})
+
+	return f
+}
+
+// WithRequestLogger ...
+func (f *StdlibRouter) WithRequestLogger() AppRouter {
+	f.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+
+			next.ServeHTTP(sw, r)
+
+			log.Trace().
+				Str("method", r.Method).
+				Int("code", sw.code).
+				Str("uri", r.RequestURI).
+				Str("from", r.RemoteAddr).
+				Send()
+		})
+	})
+
+	return f
+}
+
+// WithTracing adds a server span to every request
+func (f *StdlibRouter) WithTracing() AppRouter {
+	id := strings.ReplaceAll(f.opts.ID, "-", "_")
+
+	f.UseHandler(func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, id)
+	})
+
+	return f
+}
+
+// WithFrontend serves a single page app from data for GET requests that match no route
+func (f *StdlibRouter) WithFrontend(data fs.FS, opts ...FrontendOptions) AppRouter {
+	f.frontend = newFrontend(data, f.opts, opts...)
+
+	return f
+}
+
+// Options returns the options of the router
+func (f *StdlibRouter) Options() *AppRouterOptions {
+	return f.opts
+}
+
+// WithQueue mounts the task monitor and dead letter API of q at /monitoring/tasks, protected by the Monitor options
+func (f *StdlibRouter) WithQueue(q *queue.Queue) AppRouter {
+	_, mon := q.WithMonitor(f.opts.Monitor).MountMonitor()
+
+	if mon != nil {
+		f.mux.Handle("/monitoring/tasks/", mon)
+
+		log.Trace().Msgf("Asynq running on http://0.0.0.0:%d/monitoring/tasks", f.opts.Port)
+	}
+
+	return f
+}
+
+// WithHandler serves h at path for every method
+func (f *StdlibRouter) WithHandler(path string, h http.Handler) AppRouter {
+	f.mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(routeKey{}).(*routeInfo); ok {
+			info.path = path
+		}
+
+		h.ServeHTTP(w, r)
+	}))
+
+	return f
+}
+
+// WithMetrics records request metrics through mp and serves reg at /metrics
+func (f *StdlibRouter) WithMetrics(mp metric.MeterProvider, reg *prometheus.Registry) AppRouter {
+	m := newHTTPMetrics(mp)
+
+	f.mux.Handle("GET "+f.opts.metricsPath(), metricsHandler(reg))
+
+	f.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := m.start(r.Context(), r.Method, scheme(r), r.ContentLength)
+			info := &routeInfo{}
+			sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), routeKey{}, info)))
+
+			req.finish(info.path, sw.code, sw.size)
+		})
+	})
+
+	return f
+}
+
+// WithRoute ...
+func (f *StdlibRouter) WithRoute(method, path string, fn []HandlerFunc) AppRouter {
+	pattern, names := stdlibPattern(path)
+
+	if method != MethodAny {
+		pattern = method + " " + pattern
+	}
+
+	f.mux.Handle(pattern, mapGolainHandlerToStdlib(path, names, fn))
+
+	return f
+}
+
+// Reflector returns the OpenAPI reflector routes are documented in
+func (f *StdlibRouter) Reflector() *openapi3.Reflector {
+	return f.ref
+}
+
+// Run starts the server and blocks until it fails or is shut down
+func (f *StdlibRouter) Run() error {
+	for _, host := range addresses() {
+		log.
+			Info().
+			Str("id", f.opts.ID).
+			Str("URL", fmt.Sprintf("http://%s:%d", host, f.opts.Port)).
+			Str("OpenAPI", docsURL(host, f.opts)).
+			Send()
+	}
+
+	log.Info().Msgf("%s started with net/http 🚀", f.opts.ID)
+
+	if err := f.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done
+func (f *StdlibRouter) Shutdown(ctx context.Context) error {
+	return f.srv.Shutdown(ctx)
+}
+
+// WithStdlib ...
+func WithStdlib(port int, opts ...AppRouterOptions) Option {
+	return WithAppRouter(newStdlibRouter(mergeOptions(append(opts, AppRouterOptions{Port: port})...)))
+}
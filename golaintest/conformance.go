@@ -0,0 +1,481 @@
+// Package golaintest provides a conformance suite for golain.AppRouter backends
+package golaintest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khvh/golain/golain"
+)
+
+// Factory creates an app router option for a port, golain.WithEcho, golain.WithFiber and golain.WithStdlib are factories
+type Factory func(port int, opts ...golain.AppRouterOptions) golain.Option
+
+// Item is the payload used by conformance routes
+type Item struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+type itemParams struct {
+	ID string `path:"id"`
+}
+
+type itemQuery struct {
+	Tags  []string `query:"tag"`
+	Limit int      `query:"limit"`
+}
+
+type itemHeaders struct {
+	Token string `header:"x-token"`
+}
+
+var instances int64
+
+// RunConformance starts an app built by factory and checks it over HTTP
+func RunConformance(t *testing.T, factory Factory) {
+	t.Helper()
+
+	port := freePort(t)
+	id := fmt.Sprintf("conformance_%d", atomic.AddInt64(&instances, 1))
+
+	g := golain.New(factory(port, golain.AppRouterOptions{ID: id, Banner: true})).
+		WithDefaultMiddleware().
+		EnableMetrics()
+
+	stopped := false
+
+	g.OnStop(func(ctx context.Context) error {
+		stopped = true
+
+		return nil
+	})
+
+	registerRoutes(g)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- g.Run()
+	}()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	waitForServer(t, port)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		code   int
+		check  func(t *testing.T, res *http.Response, body []byte)
+	}{
+		{
+			name:   "get returns 200 with JSON",
+			method: http.MethodGet,
+			path:   "/conformance/items",
+			code:   http.StatusOK,
+			check:  expectJSON(`[{"id":"1","name":"one"}]`),
+		},
+		{
+			name:   "custom status code",
+			method: http.MethodPost,
+			path:   "/conformance/items",
+			body:   `{"name":"two"}`,
+			code:   http.StatusCreated,
+			check:  expectJSON(`{"id":"","name":"two"}`),
+		},
+		{
+			name:   "put reads body and path params",
+			method: http.MethodPut,
+			path:   "/conformance/items/7",
+			body:   `{"name":"seven"}`,
+			code:   http.StatusOK,
+			check:  expectJSON(`{"id":"7","name":"seven"}`),
+		},
+		{
+			name:   "patch reads body",
+			method: http.MethodPatch,
+			path:   "/conformance/items/7",
+			body:   `{"name":"patched"}`,
+			code:   http.StatusOK,
+			check:  expectJSON(`{"id":"7","name":"patched"}`),
+		},
+		{
+			name:   "delete without response is 204",
+			method: http.MethodDelete,
+			path:   "/conformance/items/7",
+			code:   http.StatusNoContent,
+			check:  expectEmpty,
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			path:   "/conformance/items",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "any method",
+			method: http.MethodPost,
+			path:   "/conformance/any",
+			code:   http.StatusOK,
+			check:  expectJSON(`"POST"`),
+		},
+		{
+			name:   "repeated query values",
+			method: http.MethodGet,
+			path:   "/conformance/query?tag=a&tag=b&limit=5",
+			code:   http.StatusOK,
+			check:  expectJSON(`{"Tags":["a","b"],"Limit":5}`),
+		},
+		{
+			name:   "invalid query is 400",
+			method: http.MethodGet,
+			path:   "/conformance/query?limit=x",
+			code:   http.StatusBadRequest,
+			check:  expectErrorCode(golain.CodeBadRequest),
+		},
+		{
+			name:   "headers",
+			method: http.MethodGet,
+			path:   "/conformance/headers",
+			header: map[string]string{"X-Token": "secret"},
+			code:   http.StatusOK,
+			check:  expectJSON(`{"Token":"secret"}`),
+		},
+		{
+			name:   "validation errors are 400",
+			method: http.MethodPost,
+			path:   "/conformance/items",
+			body:   `{}`,
+			code:   http.StatusBadRequest,
+			check:  expectErrorCode(golain.CodeValidationFailed),
+		},
+		{
+			name:   "handlers run in order",
+			method: http.MethodGet,
+			path:   "/conformance/chain",
+			code:   http.StatusOK,
+			check:  expectJSON(`["first","second","third"]`),
+		},
+		{
+			name:   "handler response stops the chain",
+			method: http.MethodGet,
+			path:   "/conformance/chain?stop=true",
+			code:   http.StatusUnauthorized,
+			check:  expectJSON(`["first"]`),
+		},
+		{
+			name:   "panics are recovered",
+			method: http.MethodGet,
+			path:   "/conformance/panic",
+			code:   http.StatusInternalServerError,
+		},
+		{
+			name:   "unknown route is 404",
+			method: http.MethodGet,
+			path:   "/conformance/missing",
+			code:   http.StatusNotFound,
+		},
+		{
+			name:   "request id header",
+			method: http.MethodGet,
+			path:   "/conformance/items",
+			code:   http.StatusOK,
+			check: func(t *testing.T, res *http.Response, body []byte) {
+				if res.Header.Get("X-Request-Id") == "" {
+					t.Error("missing X-Request-Id header")
+				}
+			},
+		},
+		{
+			name:   "cors",
+			method: http.MethodGet,
+			path:   "/conformance/items",
+			header: map[string]string{"Origin": "http://example.com"},
+			code:   http.StatusOK,
+			check: func(t *testing.T, res *http.Response, body []byte) {
+				if res.Header.Get("Access-Control-Allow-Origin") == "" {
+					t.Error("missing Access-Control-Allow-Origin header")
+				}
+			},
+		},
+		{
+			name:   "openapi spec",
+			method: http.MethodGet,
+			path:   "/docs/openapi.json",
+			code:   http.StatusOK,
+			check:  expectContains(`"/conformance/items/{id}"`),
+		},
+		{
+			name:   "metrics",
+			method: http.MethodGet,
+			path:   "/metrics",
+			code:   http.StatusOK,
+			check:  expectContains("# TYPE"),
+		},
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, base+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tc.code {
+				t.Fatalf("expected status %d, got %d: %s", tc.code, res.StatusCode, body)
+			}
+
+			if tc.check != nil {
+				tc.check(t, res, body)
+			}
+		})
+	}
+
+	t.Run("shutdown drains in-flight requests", func(t *testing.T) {
+		inflight := make(chan int, 1)
+
+		go func() {
+			res, err := client.Get(base + "/conformance/slow")
+			if err != nil {
+				inflight <- 0
+
+				return
+			}
+
+			res.Body.Close()
+
+			inflight <- res.StatusCode
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := g.Shutdown(ctx); err != nil {
+			t.Fatalf("shutdown failed: %s", err)
+		}
+
+		if code := <-inflight; code != http.StatusOK {
+			t.Errorf("expected in-flight request to finish with 200, got %d", code)
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("run returned %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("run did not return after shutdown")
+		}
+
+		if !stopped {
+			t.Error("stop hooks did not run")
+		}
+	})
+}
+
+func registerRoutes(g *golain.Golain) {
+	stored := []Item{{ID: "1", Name: "one"}}
+
+	step := func(name string) golain.HandlerFunc {
+		return func(c *golain.Ctx) *golain.Res {
+			steps, _ := c.Get("steps").([]string)
+			c.Set("steps", append(steps, name))
+
+			if name == "first" && c.Query["stop"] == "true" {
+				return c.JSON(c.Get("steps"), http.StatusUnauthorized)
+			}
+
+			return nil
+		}
+	}
+
+	g.RegisterRouter(
+		golain.NewRouter().
+			Prefix("/conformance").
+			Group("Conformance").
+			Register(
+				golain.Get[[]Item]("/items", func(c *golain.Ctx) *golain.Res {
+					return c.JSON(stored)
+				}),
+				golain.Head[[]Item]("/items", func(c *golain.Ctx) *golain.Res {
+					return c.JSON(nil)
+				}),
+				golain.Post[Item, Item]("/items", func(c *golain.Ctx) *golain.Res {
+					item, err := golain.Body[Item](c)
+					if err != nil {
+						return c.BadRequest(err)
+					}
+
+					return c.JSON(item, http.StatusCreated)
+				}),
+				golain.Put[Item, Item]("/items/:id", updateItem),
+				golain.Patch[Item, Item]("/items/:id", updateItem),
+				golain.Delete[any]("/items/:id", func(c *golain.Ctx) *golain.Res {
+					return nil
+				}),
+				golain.Any[string, any]("/any", func(c *golain.Ctx) *golain.Res {
+					return c.JSON(c.Method)
+				}),
+				golain.Get[itemQuery]("/query", func(c *golain.Ctx) *golain.Res {
+					q, err := golain.Query[itemQuery](c)
+					if err != nil {
+						return c.BadRequest(err)
+					}
+
+					return c.JSON(q)
+				}),
+				golain.Get[itemHeaders]("/headers", func(c *golain.Ctx) *golain.Res {
+					h, err := golain.Headers[itemHeaders](c)
+					if err != nil {
+						return c.BadRequest(err)
+					}
+
+					return c.JSON(h)
+				}),
+				golain.Get[[]string]("/chain", step("first"), step("second"), step("third"), func(c *golain.Ctx) *golain.Res {
+					return c.JSON(c.Get("steps"))
+				}),
+				golain.Get[any]("/slow", func(c *golain.Ctx) *golain.Res {
+					time.Sleep(200 * time.Millisecond)
+
+					return c.JSON("done")
+				}),
+				golain.Get[any]("/panic", func(c *golain.Ctx) *golain.Res {
+					panic("conformance")
+				}),
+			),
+	)
+}
+
+func updateItem(c *golain.Ctx) *golain.Res {
+	item, err := golain.Body[Item](c)
+	if err != nil {
+		return c.BadRequest(err)
+	}
+
+	params, err := golain.Params[itemParams](c)
+	if err != nil {
+		return c.BadRequest(err)
+	}
+
+	item.ID = params.ID
+
+	return c.JSON(item)
+}
+
+func expectJSON(expected string) func(t *testing.T, res *http.Response, body []byte) {
+	return func(t *testing.T, res *http.Response, body []byte) {
+		var want, got any
+
+		if err := json.Unmarshal([]byte(expected), &want); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("invalid JSON %q: %s", body, err)
+		}
+
+		w, _ := json.Marshal(want)
+		g, _ := json.Marshal(got)
+
+		if string(w) != string(g) {
+			t.Errorf("expected %s, got %s", w, g)
+		}
+	}
+}
+
+func expectErrorCode(code string) func(t *testing.T, res *http.Response, body []byte) {
+	return func(t *testing.T, res *http.Response, body []byte) {
+		e := struct {
+			Code string `json:"code"`
+		}{}
+
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatalf("invalid JSON %q: %s", body, err)
+		}
+
+		if e.Code != code {
+			t.Errorf("expected error code %q, got %q", code, e.Code)
+		}
+	}
+}
+
+func expectContains(s string) func(t *testing.T, res *http.Response, body []byte) {
+	return func(t *testing.T, res *http.Response, body []byte) {
+		if !strings.Contains(string(body), s) {
+			t.Errorf("expected body to contain %q", s)
+		}
+	}
+}
+
+func expectEmpty(t *testing.T, res *http.Response, body []byte) {
+	if len(body) > 0 {
+		t.Errorf("expected empty body, got %q", body)
+	}
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitForServer(t *testing.T, port int) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("server did not start on port %d", port)
+}
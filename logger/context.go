@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ctx returns the logger stored in ctx with zerolog's WithContext, the global logger when there is none
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if ctx == nil {
+		return &log.Logger
+	}
+
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+
+	return &log.Logger
+}
+
+// WithSpan adds the trace and span IDs of the span in ctx to c, so logs can be joined with traces
+func WithSpan(ctx context.Context, c zerolog.Context) zerolog.Context {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		c = c.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+	}
+
+	return c
+}
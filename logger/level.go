@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// levelSampler drops logs below the level of a component, the default level when component is empty,
+// before they are built and passes the rest to the next sampler
+type levelSampler struct {
+	component string
+	next      zerolog.Sampler
+}
+
+func (s levelSampler) Sample(level zerolog.Level) bool {
+	if level != zerolog.NoLevel && level < levelOf(s.component) {
+		return false
+	}
+
+	return s.next == nil || s.next.Sample(level)
+}
+
+func levelOf(component string) zerolog.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if l, ok := levels[component]; ok {
+		return l
+	}
+
+	return defaults
+}
+
+// updateGlobalLevel lets through logs of the most verbose level in use, the samplers filter the rest
+func updateGlobalLevel() {
+	lowest := defaults
+
+	for _, l := range levels {
+		if l < lowest {
+			lowest = l
+		}
+	}
+
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// ParseLevel parses a level name like "warn" or "WARNING", or a zerolog level number. An empty string is info.
+func ParseLevel(s string) (zerolog.Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch s {
+	case "":
+		return zerolog.InfoLevel, nil
+	case "warning":
+		return zerolog.WarnLevel, nil
+	case "off", "none":
+		return zerolog.Disabled, nil
+	}
+
+	if n, err := strconv.Atoi(s); err == nil && n >= int(zerolog.TraceLevel) && n <= int(zerolog.Disabled) {
+		return zerolog.Level(n), nil
+	}
+
+	l, err := zerolog.ParseLevel(s)
+	if err != nil || l == zerolog.NoLevel {
+		return zerolog.NoLevel, fmt.Errorf("unknown log level %q", s)
+	}
+
+	return l, nil
+}
+
+// ParseComponents parses component levels in the form "asynq=warn,queue=debug"
+func ParseComponents(s string) (map[string]string, error) {
+	components := map[string]string{}
+
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		name, level, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid component level %q, expected name=level", part)
+		}
+
+		if _, err := ParseLevel(level); err != nil {
+			return nil, err
+		}
+
+		components[strings.TrimSpace(name)] = strings.TrimSpace(level)
+	}
+
+	return components, nil
+}
+
+// SetLevel changes the level of a component at runtime, an empty component changes the default level
+// and an empty level removes the override of a component
+func SetLevel(component, level string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch {
+	case component == "":
+		l, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+
+		defaults = l
+	case level == "":
+		delete(levels, component)
+	default:
+		l, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+
+		levels[component] = l
+	}
+
+	updateGlobalLevel()
+
+	return nil
+}
+
+// Levels describes the default level and the component levels
+type Levels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// SetLevels replaces the default level and all component levels at runtime, e.g. after the config was reloaded
+func SetLevels(l Levels) error {
+	level, err := ParseLevel(l.Level)
+	if err != nil {
+		return err
+	}
+
+	overrides := map[string]zerolog.Level{}
+
+	for name, s := range l.Components {
+		if overrides[name], err = ParseLevel(s); err != nil {
+			return fmt.Errorf("component %s: %w", name, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaults = level
+	levels = overrides
+
+	updateGlobalLevel()
+
+	return nil
+}
+
+// CurrentLevels returns the levels in use
+func CurrentLevels() Levels {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	current := Levels{Level: defaults.String(), Components: map[string]string{}}
+
+	for name, l := range levels {
+		current.Components[name] = l.String()
+	}
+
+	return current
+}
+
+// levelChange is the body of a level change request
+type levelChange struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+}
+
+// Handler serves the levels in use on GET and changes a level on PUT or POST with a JSON body like
+// {"component": "asynq", "level": "debug"}, the component is optional
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			var change levelChange
+
+			if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			if err := SetLevel(change.Component, change.Level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			Component("logger").Info().Str("component", change.Component).Str("to", change.Level).Msg("Log level changed")
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		_ = json.NewEncoder(w).Encode(CurrentLevels())
+	})
+}
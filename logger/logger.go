@@ -1,18 +1,218 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Format selects how logs are written
+type Format string
+
+// Supported formats
+const (
+	FormatJSON    Format = "json"
+	FormatConsole Format = "console"
+)
+
+// Config configures logging.
+//
+// Level is the level of logs without a component, info by default. Components overrides it for loggers
+// returned by Component, e.g. {"asynq": "warn"}. Output is stderr, stdout or the path of a file that is
+// rotated as described by Rotation. Sampling thins out trace, debug and info logs, warnings and errors are
+// always written.
+type Config struct {
+	Level      string
+	Format     Format
+	Output     string
+	Components map[string]string
+	Sampling   Sampling
+	Rotation   Rotation
+}
+
+// Sampling lets Burst logs per Period through and then every Every-th log until the period is over, an Every
+// of 0 drops them. Without a Burst every Every-th log is written.
+type Sampling struct {
+	Burst  uint32
+	Period time.Duration
+	Every  uint32
+}
+
+// Rotation sets when a log file is rotated, MaxSize is in megabytes and 100 by default, MaxAge is in days,
+// 0 keeps old files forever
+type Rotation struct {
+	MaxSize    int
+	MaxBackups int
+	MaxAge     int
+	Compress   bool
+}
+
+// CloseFunc closes the log file, it is a no-op for stdout and stderr
+type CloseFunc func() error
+
+var (
+	mu         sync.RWMutex
+	configured bool
+	base       zerolog.Logger
+	sampler    zerolog.Sampler
+	defaults   = zerolog.InfoLevel
+	levels     = map[string]zerolog.Level{}
+	components = map[string]*zerolog.Logger{}
 )
 
 // Init logger for dev or prod
 func Init(devMode bool) {
 	if devMode {
-		zerolog.SetGlobalLevel(zerolog.TraceLevel)
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+		_, _ = New(Config{Level: "trace", Format: FormatConsole})
 	} else {
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+		_, _ = New(Config{Level: "error"})
+	}
+}
+
+// New sets up the global logger as described by cfg, it can be called again to reconfigure logging
+func New(cfg Config) (CloseFunc, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]zerolog.Level{}
+
+	for name, s := range cfg.Components {
+		if overrides[name], err = ParseLevel(s); err != nil {
+			return nil, fmt.Errorf("component %s: %w", name, err)
+		}
+	}
+
+	w, closeFn, err := newWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var s zerolog.Sampler
+
+	if cfg.Sampling.Burst > 0 || cfg.Sampling.Every > 1 {
+		s = newSampler(cfg.Sampling)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	configured = true
+	base = zerolog.New(w).With().Timestamp().Logger()
+	sampler = s
+	defaults = level
+	levels = overrides
+	components = map[string]*zerolog.Logger{}
+	log.Logger = base.Sample(levelSampler{next: sampler})
+
+	updateGlobalLevel()
+
+	return closeFn, nil
+}
+
+// newWriter opens the output of cfg
+func newWriter(cfg Config) (io.Writer, CloseFunc, error) {
+	var (
+		w       io.Writer
+		closeFn CloseFunc = func() error { return nil }
+		file    bool
+	)
+
+	switch cfg.Output {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		f := &lumberjack.Logger{
+			Filename:   cfg.Output,
+			MaxSize:    cfg.Rotation.MaxSize,
+			MaxBackups: cfg.Rotation.MaxBackups,
+			MaxAge:     cfg.Rotation.MaxAge,
+			Compress:   cfg.Rotation.Compress,
+		}
+
+		w, closeFn, file = f, f.Close, true
+	}
+
+	switch cfg.Format {
+	case "", FormatJSON:
+		return w, closeFn, nil
+	case FormatConsole:
+		return zerolog.ConsoleWriter{Out: w, NoColor: file}, closeFn, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported log format %q", cfg.Format)
+	}
+}
+
+// newSampler samples trace, debug and info logs
+func newSampler(s Sampling) zerolog.Sampler {
+	var sampler zerolog.Sampler
+
+	if s.Every > 0 {
+		sampler = &zerolog.BasicSampler{N: s.Every}
+	}
+
+	if s.Burst > 0 {
+		sampler = &zerolog.BurstSampler{Burst: s.Burst, Period: s.Period, NextSampler: sampler}
+	}
+
+	return zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler, InfoSampler: sampler}
+}
+
+// Component returns the logger of a component, its logs have a "from" field with name and follow the
+// level set for the component
+func Component(name string) *zerolog.Logger {
+	mu.RLock()
+	l, ok := components[name]
+	mu.RUnlock()
+
+	if ok {
+		return l
 	}
+
+	if !configured {
+		l := log.Logger.With().Str("from", name).Logger()
+
+		return &l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if l, ok := components[name]; ok {
+		return l
+	}
+
+	c := base.With().Str("from", name).Logger().Sample(levelSampler{component: name, next: sampler})
+	components[name] = &c
+
+	return &c
+}
+
+// FromEnv returns a config read from the LOG_LEVEL, LOG_FORMAT, LOG_OUTPUT and LOG_COMPONENTS environment
+// variables, prefixed with prefix. LOG_COMPONENTS has the form "asynq=warn,queue=debug".
+func FromEnv(prefix string) (Config, error) {
+	cfg := Config{
+		Level:  os.Getenv(prefix + "LOG_LEVEL"),
+		Format: Format(strings.ToLower(os.Getenv(prefix + "LOG_FORMAT"))),
+		Output: os.Getenv(prefix + "LOG_OUTPUT"),
+	}
+
+	components, err := ParseComponents(os.Getenv(prefix + "LOG_COMPONENTS"))
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Components = components
+
+	return cfg, nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"sync"
 
 	"github.com/khvh/golain/api"
@@ -19,6 +20,15 @@ func t1(c *golain.Ctx) *golain.Res {
 	return c.JSON(&TestType{ID: "1"})
 }
 
+// queueURL returns QUEUE_URL, without it each app gets its own in-process queue
+func queueURL() string {
+	if url := os.Getenv("QUEUE_URL"); url != "" {
+		return url
+	}
+
+	return "memory://"
+}
+
 func main() {
 	logger.Init(true)
 
@@ -44,13 +54,14 @@ func main() {
 	wg.Add(2)
 
 	go func() {
-		golain.
+		if err := golain.
 			New(
 				golain.WithFiber(12345, golain.AppRouterOptions{Banner: true, ID: "fib"}),
+				golain.WithQueueConcurrency(4),
 			).
 			EnableMetrics().
 			EnableTracing().
-			EnableQueue("127.0.0.1:6379", "", queue.Queues{
+			EnableQueue(queueURL(), "", queue.Queues{
 				"critical": 6,
 				"default":  3,
 				"low":      1,
@@ -60,17 +71,20 @@ func main() {
 					golain.Get[TestType]("/test-path", t1),
 				)
 			}).
-			Run()
+			Run(); err != nil {
+			log.Err(err).Send()
+		}
+
 		wg.Done()
 	}()
 
 	go func() {
-		golain.
+		if err := golain.
 			New(golain.WithEcho(7777, golain.AppRouterOptions{Banner: true, ID: "ech"})).
 			WithDefaultMiddleware().
 			EnableMetrics().
 			EnableTracing().
-			EnableQueue("127.0.0.1:6379", "", queue.Queues{
+			EnableQueue(queueURL(), "", queue.Queues{
 				"critical": 6,
 				"default":  3,
 				"low":      1,
@@ -80,7 +94,10 @@ func main() {
 					golain.Get[TestType]("/test-path", t1),
 				)
 			}).
-			Run()
+			Run(); err != nil {
+			log.Err(err).Send()
+		}
+
 		wg.Done()
 	}()
 
@@ -5,8 +5,10 @@ import (
 	"log"
 	"net/http"
 	"path"
+	"reflect"
 	"strings"
 
+	"github.com/khvh/golain/validate"
 	"github.com/swaggest/openapi-go/openapi3"
 )
 
@@ -22,7 +24,7 @@ type Error struct {
 
 // Err is the constructor for Error
 func Err(code string) *Error {
-	return &Error{}
+	return &Error{Code: code}
 }
 
 // Message sets the message for Error
@@ -39,6 +41,14 @@ func (e *Error) Data(data *JSONObject) *Error {
 	return e
 }
 
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
 type apiResponse struct {
 	code int
 	body interface{}
@@ -150,6 +160,53 @@ func (o *OAS) Patch(body interface{}, data interface{}, code ...int) *OAS {
 	return o
 }
 
+// Head handles the HEAD request spec
+func (o *OAS) Head(body interface{}, code ...int) *OAS {
+	o.
+		response(body, getCode(code...)).
+		withNotFound().
+		withInternalError().
+		withMethod(http.MethodHead)
+
+	return o
+}
+
+// Options handles the OPTIONS request spec
+func (o *OAS) Options(body interface{}, code ...int) *OAS {
+	o.
+		response(body, getCode(code...)).
+		withInternalError().
+		withMethod(http.MethodOptions)
+
+	return o
+}
+
+// Any handles the spec for a route accepting every method, it is documented under GET, POST, PUT, PATCH and DELETE
+func (o *OAS) Any(body interface{}, data interface{}, code ...int) *OAS {
+	o.
+		request(data).
+		response(body, getCode(code...)).
+		withNotFound().
+		withBadRequest().
+		withInternalError().
+		withMethod("")
+
+	return o
+}
+
+// Clone returns a copy of the spec that can be modified independently
+func (o *OAS) Clone() *OAS {
+	c := *o
+
+	c.params = append([]string{}, o.params...)
+	c.headers = append([]string{}, o.headers...)
+	c.query = append([]string{}, o.query...)
+	c.out = append([]*apiResponse{}, o.out...)
+	c.tags = append([]string{}, o.tags...)
+
+	return &c
+}
+
 // AddSummary adds a summary for the route
 func (o *OAS) AddSummary(summary string) *OAS {
 	o.summary = summary
@@ -274,6 +331,18 @@ func (o *OAS) createParam(id, location string) *openapi3.Parameter {
 
 // Build constructs the OpenAPI spec for a single request
 func (o *OAS) Build(ref *openapi3.Reflector) *OAS {
+	if o.method == "" {
+		for _, method := range anyMethods {
+			o.build(ref, method)
+		}
+
+		return o
+	}
+
+	return o.build(ref, o.method)
+}
+
+func (o *OAS) build(ref *openapi3.Reflector, method string) *OAS {
 	op := openapi3.Operation{}
 
 	var (
@@ -308,15 +377,37 @@ func (o *OAS) Build(ref *openapi3.Reflector) *OAS {
 		handleError(ref.SetJSONResponse(&op, response.body, response.code))
 	}
 
-	if o.method == http.MethodPost || o.method == http.MethodPut || o.method == http.MethodPatch {
-		handleError(ref.SetRequest(&op, o.in, o.method))
+	// GET and HEAD reflect only the parameters of the request type, the other methods also its body
+	if o.in != nil {
+		handleError(ref.SetRequest(&op, o.in, method))
+
+		o.requireParams(&op)
 	}
 
-	handleError(ref.Spec.AddOperation(o.method, path.Clean(o.path), op))
+	handleError(ref.Spec.AddOperation(method, path.Clean(o.path), op))
 
 	return o
 }
 
+// requireParams marks parameters reflected from the request type as required when their field has the required rule
+func (o *OAS) requireParams(op *openapi3.Operation) {
+	if o.in == nil {
+		return
+	}
+
+	t := reflect.TypeOf(o.in)
+
+	for _, p := range op.Parameters {
+		if p.Parameter == nil || p.Parameter.Required != nil && *p.Parameter.Required {
+			continue
+		}
+
+		if validate.IsRequired(t, string(p.Parameter.In), p.Parameter.Name) {
+			p.Parameter.WithRequired(true)
+		}
+	}
+}
+
 func handleError(err error) {
 	if err != nil {
 		log.Print(err)
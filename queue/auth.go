@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/khvh/golain/logger"
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+)
+
+// Authenticator reports whether a request to the monitor is allowed
+type Authenticator func(r *http.Request) bool
+
+// MonitorOptions protects the task monitor and the dead letter API at /monitoring/tasks. Users, Tokens,
+// JWTSecret and Auth are alternatives, a request is let in when any of them accepts it, and without them
+// the dashboard is open. AllowIPs restricts requests by client address on top of that, the address is taken
+// from the last X-Forwarded-For entry when TrustForwardedFor is set. ReadOnly allows only GET and HEAD.
+type MonitorOptions struct {
+	ReadOnly          bool
+	Users             map[string]string
+	Tokens            []string
+	JWTSecret         string
+	AllowIPs          []string
+	TrustForwardedFor bool
+	Auth              []Authenticator
+}
+
+// monitor holds the monitor options of a queue
+type monitor struct {
+	readOnly          bool
+	basic             bool
+	trustForwardedFor bool
+	allowIPs          []netip.Prefix
+	auth              []Authenticator
+}
+
+// BasicAuth allows requests with HTTP basic auth credentials in users, a map of user names to passwords
+func BasicAuth(users map[string]string) Authenticator {
+	return func(r *http.Request) bool {
+		user, pw, ok := r.BasicAuth()
+		if !ok {
+			return false
+		}
+
+		want, ok := users[user]
+
+		return ok && subtle.ConstantTimeCompare([]byte(pw), []byte(want)) == 1
+	}
+}
+
+// BearerToken allows requests with an "Authorization: Bearer <token>" header
+func BearerToken(token string) Authenticator {
+	return func(r *http.Request) bool {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+}
+
+// JWT allows requests with a bearer JWT signed with secret using HMAC, exp and nbf claims are checked
+func JWT(secret []byte) Authenticator {
+	keyFunc := func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		return secret, nil
+	}
+
+	return func(r *http.Request) bool {
+		raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || len(secret) == 0 {
+			return false
+		}
+
+		token, err := jwt.Parse(raw, keyFunc)
+
+		return err == nil && token.Valid
+	}
+}
+
+// WithAPIAuth adds ways requests to the monitor are authenticated, a request is allowed when any of auth
+// allows it. The dead letter API refuses every request until an Authenticator is set.
+func (q *Queue) WithAPIAuth(auth ...Authenticator) *Queue {
+	q.monitor.auth = append(q.monitor.auth, auth...)
+
+	return q
+}
+
+// WithMonitor protects the monitor with opts, it can be called more than once and has to be called before
+// MountMonitor
+func (q *Queue) WithMonitor(opts MonitorOptions) *Queue {
+	m := &q.monitor
+
+	m.readOnly = m.readOnly || opts.ReadOnly
+	m.trustForwardedFor = m.trustForwardedFor || opts.TrustForwardedFor
+
+	if len(opts.Users) > 0 {
+		m.basic = true
+		m.auth = append(m.auth, BasicAuth(opts.Users))
+	}
+
+	for _, token := range opts.Tokens {
+		m.auth = append(m.auth, BearerToken(token))
+	}
+
+	if opts.JWTSecret != "" {
+		m.auth = append(m.auth, JWT([]byte(opts.JWTSecret)))
+	}
+
+	m.auth = append(m.auth, opts.Auth...)
+
+	for _, ip := range opts.AllowIPs {
+		prefix, err := parsePrefix(ip)
+		if err != nil {
+			logger.Component("queue").Err(err).Msg("Invalid monitor IP allowlist entry")
+
+			continue
+		}
+
+		m.allowIPs = append(m.allowIPs, prefix)
+	}
+
+	return q
+}
+
+// parsePrefix parses an IP address or a CIDR
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// protect applies the monitor options to requests under root
+func (q *Queue) protect(root string, next http.Handler) http.Handler {
+	base := root + deadLetterPath
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := &q.monitor
+
+		if len(m.allowIPs) > 0 && !m.allowed(r) {
+			writeJSON(w, nil, errForbidden)
+
+			return
+		}
+
+		if (len(m.auth) > 0 || isDeadLetterPath(base, r)) && !m.authenticated(r) {
+			if m.basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="monitoring"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+
+			writeJSON(w, nil, errUnauthorized)
+
+			return
+		}
+
+		if m.readOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeJSON(w, nil, errForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *monitor) authenticated(r *http.Request) bool {
+	for _, auth := range m.auth {
+		if auth(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed reports whether the client address is in the allowlist
+func (m *monitor) allowed(r *http.Request) bool {
+	raw := r.RemoteAddr
+
+	if m.trustForwardedFor {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			raw = strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(raw)
+		if err != nil {
+			return false
+		}
+
+		addr = addrPort.Addr()
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range m.allowIPs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+)
+
+// Backend processes tasks, *asynq.Server and *MemoryBackend implement it
+type Backend interface {
+	Start(handler asynq.Handler) error
+	Shutdown()
+}
+
+// Enqueuer adds tasks to queues, *asynq.Client and *MemoryBackend implement it
+type Enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+	Close() error
+}
+
+// noEnqueuer fails to add tasks with err
+type noEnqueuer struct {
+	err error
+}
+
+func (e noEnqueuer) EnqueueContext(context.Context, *asynq.Task, ...asynq.Option) (*asynq.TaskInfo, error) {
+	return nil, e.err
+}
+
+func (e noEnqueuer) Close() error {
+	return nil
+}
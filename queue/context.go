@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"github.com/khvh/golain/logger"
+	"github.com/rs/zerolog"
+)
+
+type taskKey struct{}
+
+func taskInfo(ctx context.Context) (*asynq.TaskInfo, bool) {
+	info, ok := ctx.Value(taskKey{}).(*asynq.TaskInfo)
+
+	return info, ok
+}
+
+// TaskID returns the ID of the task being processed, it works with every backend
+func TaskID(ctx context.Context) (string, bool) {
+	if info, ok := taskInfo(ctx); ok {
+		return info.ID, true
+	}
+
+	return asynq.GetTaskID(ctx)
+}
+
+// RetryCount returns how many times the task being processed has been retried
+func RetryCount(ctx context.Context) (int, bool) {
+	if info, ok := taskInfo(ctx); ok {
+		return info.Retried, true
+	}
+
+	return asynq.GetRetryCount(ctx)
+}
+
+// MaxRetry returns how many times the task being processed can be retried
+func MaxRetry(ctx context.Context) (int, bool) {
+	if info, ok := taskInfo(ctx); ok {
+		return info.MaxRetry, true
+	}
+
+	return asynq.GetMaxRetry(ctx)
+}
+
+// QueueName returns the queue of the task being processed
+func QueueName(ctx context.Context) (string, bool) {
+	if info, ok := taskInfo(ctx); ok {
+		return info.Queue, true
+	}
+
+	return asynq.GetQueueName(ctx)
+}
+
+// Logger returns the logger of the task being processed, it carries the task ID and type, the queue and
+// the trace and span IDs
+func Logger(ctx context.Context) *zerolog.Logger {
+	return logger.Ctx(ctx)
+}
+
+// logging is a worker middleware adding a logger for the task to the context
+func logging(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		lc := logger.WithSpan(ctx, logger.Ctx(ctx).With()).Str("task_type", t.Type())
+
+		if id, ok := TaskID(ctx); ok {
+			lc = lc.Str("task_id", id)
+		}
+
+		if queue, ok := QueueName(ctx); ok {
+			lc = lc.Str("queue", queue)
+		}
+
+		return next.ProcessTask(lc.Logger().WithContext(ctx), t)
+	})
+}
@@ -0,0 +1,209 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const exportPageSize = 100
+
+// ErrNoInspector is returned by an Inspector for queues that can't be inspected, e.g. with a custom backend
+var ErrNoInspector = errors.New("queue can't be inspected")
+
+// DeadLetter is a task that failed permanently and was archived
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Queue    string    `json:"queue"`
+	Type     string    `json:"type"`
+	Payload  []byte    `json:"payload"`
+	Error    string    `json:"error"`
+	Retried  int       `json:"retried"`
+	MaxRetry int       `json:"max_retry"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func newDeadLetter(info *asynq.TaskInfo) *DeadLetter {
+	_, payload := unwrap(info.Payload)
+
+	return &DeadLetter{
+		ID:       info.ID,
+		Queue:    info.Queue,
+		Type:     info.Type,
+		Payload:  payload,
+		Error:    info.LastErr,
+		Retried:  info.Retried,
+		MaxRetry: info.MaxRetry,
+		FailedAt: info.LastFailedAt,
+	}
+}
+
+// Inspector lists, retries, deletes and exports archived tasks, it works with Redis and the memory backend
+type Inspector struct {
+	inspector *asynq.Inspector
+	memory    *MemoryBackend
+}
+
+// NewInspector creates an inspector for the queue described by cfg, close it when done with it. A memory://
+// backend is private to its queue, use Queue.Inspector.
+func NewInspector(cfg RedisConfig) *Inspector {
+	if cfg.IsMemory() {
+		return &Inspector{}
+	}
+
+	return &Inspector{inspector: asynq.NewInspector(cfg.ConnOpt())}
+}
+
+// Queues returns the names of the queues
+func (i *Inspector) Queues() ([]string, error) {
+	switch {
+	case i.memory != nil:
+		return i.memory.queueNames(), nil
+	case i.inspector != nil:
+		return i.inspector.Queues()
+	default:
+		return nil, ErrNoInspector
+	}
+}
+
+// DeadLetters returns a page of the archived tasks in queue, most recently failed first. Pages start at 1.
+func (i *Inspector) DeadLetters(queue string, page, size int) ([]*DeadLetter, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	var infos []*asynq.TaskInfo
+
+	switch {
+	case i.memory != nil:
+		infos = i.memory.listArchived(queue, page, size)
+	case i.inspector != nil:
+		var err error
+
+		if infos, err = i.inspector.ListArchivedTasks(queue, asynq.Page(page), asynq.PageSize(size)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNoInspector
+	}
+
+	letters := make([]*DeadLetter, 0, len(infos))
+
+	for _, info := range infos {
+		letters = append(letters, newDeadLetter(info))
+	}
+
+	return letters, nil
+}
+
+// Retry moves the archived task id back to queue to be processed again
+func (i *Inspector) Retry(queue, id string) error {
+	switch {
+	case i.memory != nil:
+		_, err := i.memory.runArchived(queue, id)
+
+		return err
+	case i.inspector != nil:
+		if err := i.archived(queue, id); err != nil {
+			return err
+		}
+
+		return i.inspector.RunTask(queue, id)
+	default:
+		return ErrNoInspector
+	}
+}
+
+// RetryAll moves every archived task in queue back to be processed again and returns how many were moved
+func (i *Inspector) RetryAll(queue string) (int, error) {
+	switch {
+	case i.memory != nil:
+		return i.memory.runArchived(queue)
+	case i.inspector != nil:
+		return i.inspector.RunAllArchivedTasks(queue)
+	default:
+		return 0, ErrNoInspector
+	}
+}
+
+// Delete deletes the archived task id in queue
+func (i *Inspector) Delete(queue, id string) error {
+	switch {
+	case i.memory != nil:
+		_, err := i.memory.deleteArchived(queue, id)
+
+		return err
+	case i.inspector != nil:
+		if err := i.archived(queue, id); err != nil {
+			return err
+		}
+
+		return i.inspector.DeleteTask(queue, id)
+	default:
+		return ErrNoInspector
+	}
+}
+
+// DeleteAll deletes every archived task in queue and returns how many were deleted
+func (i *Inspector) DeleteAll(queue string) (int, error) {
+	switch {
+	case i.memory != nil:
+		return i.memory.deleteArchived(queue)
+	case i.inspector != nil:
+		return i.inspector.DeleteAllArchivedTasks(queue)
+	default:
+		return 0, ErrNoInspector
+	}
+}
+
+// Export writes every archived task in queue to w as JSON lines and returns how many were written
+func (i *Inspector) Export(queue string, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+
+	for page := 1; ; page++ {
+		letters, err := i.DeadLetters(queue, page, exportPageSize)
+		if err != nil {
+			return n, err
+		}
+
+		for _, dl := range letters {
+			if err := enc.Encode(dl); err != nil {
+				return n, err
+			}
+
+			n++
+		}
+
+		if len(letters) < exportPageSize {
+			return n, nil
+		}
+	}
+}
+
+// Close closes the Redis connection of the inspector
+func (i *Inspector) Close() error {
+	if i.inspector != nil {
+		return i.inspector.Close()
+	}
+
+	return nil
+}
+
+// archived checks that id is an archived task, asynq would also run or delete tasks in other states
+func (i *Inspector) archived(queue, id string) error {
+	info, err := i.inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+
+	if info.State != asynq.TaskStateArchived {
+		return fmt.Errorf("task %s is %s: %w", id, info.State, asynq.ErrTaskNotFound)
+	}
+
+	return nil
+}
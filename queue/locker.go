@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Locker elects a single leader among replicas, e.g. for the Scheduler
+type Locker interface {
+	// Lock acquires or extends the lock on key for owner, reporting whether owner holds it
+	Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the lock on key if owner holds it
+	Unlock(ctx context.Context, key, owner string) error
+}
+
+// extendScript extends the lock when it is held by the owner, or takes it when it's free
+var extendScript = redis.NewScript(`
+local owner = redis.call("GET", KEYS[1])
+if owner == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if owner == false then
+	return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2]) and 1 or 0
+end
+return 0
+`)
+
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is a Locker backed by expiring Redis keys
+type RedisLocker struct {
+	client redis.UniversalClient
+}
+
+// NewRedisLocker creates a Locker using client
+func NewRedisLocker(client redis.UniversalClient) *RedisLocker {
+	return &RedisLocker{client}
+}
+
+// Lock implements Locker
+func (l *RedisLocker) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	n, err := extendScript.Run(ctx, l.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Unlock implements Locker
+func (l *RedisLocker) Unlock(ctx context.Context, key, owner string) error {
+	return unlockScript.Run(ctx, l.client, []string{key}, owner).Err()
+}
+
+// LocalLocker is a Locker for replicas in the same process, used with the memory backend. Every queue has its
+// own, replicas share one with WithLocker.
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]localLock
+}
+
+type localLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewLocalLocker creates a LocalLocker
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{locks: map[string]localLock{}}
+}
+
+// Lock implements Locker
+func (l *LocalLocker) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lock, ok := l.locks[key]; ok && lock.owner != owner && lock.expires.After(time.Now()) {
+		return false, nil
+	}
+
+	l.locks[key] = localLock{owner, time.Now().Add(ttl)}
+
+	return true, nil
+}
+
+// Unlock implements Locker
+func (l *LocalLocker) Unlock(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lock, ok := l.locks[key]; ok && lock.owner == owner {
+		delete(l.locks, key)
+	}
+
+	return nil
+}
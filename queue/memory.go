@@ -0,0 +1,520 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	memoryScheme       = "memory://"
+	defaultQueue       = "default"
+	defaultMaxRetry    = 25
+	defaultTimeout     = 30 * time.Minute
+	memoryPollInterval = 100 * time.Millisecond
+)
+
+// ErrMemoryClient is returned by clients created with NewClient for memory://, use NewClientWith(q.Backend())
+var ErrMemoryClient = errors.New("memory queue can only be reached through its backend")
+
+// IsMemoryURL reports whether url selects the in-memory backend, e.g. memory://. Every server created
+// with it gets its own backend.
+func IsMemoryURL(url string) bool {
+	return strings.HasPrefix(url, memoryScheme)
+}
+
+// MemoryBackend processes tasks in-process without Redis, it supports queue priorities,
+// retries, delays, scheduled tasks, task IDs and uniqueness. Task groups and retention are ignored.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	concurrency int
+	queues      Queues
+	retryDelay  asynq.RetryDelayFunc
+	offset      time.Duration
+	tasks       map[string]*memoryTask
+	pending     map[string][]*memoryTask
+	scheduled   []*memoryTask
+	unique      map[string]time.Time
+	handler     asynq.Handler
+	notify      chan struct{}
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+type memoryTask struct {
+	info      asynq.TaskInfo
+	uniqueKey string
+}
+
+// NewMemoryBackend creates an in-memory backend processing qs with concurrency workers
+func NewMemoryBackend(concurrency int, qs Queues) *MemoryBackend {
+	m := &MemoryBackend{
+		retryDelay: asynq.DefaultRetryDelayFunc,
+		tasks:      map[string]*memoryTask{},
+		pending:    map[string][]*memoryTask{},
+		unique:     map[string]time.Time{},
+		notify:     make(chan struct{}, 1),
+	}
+
+	return m.configure(concurrency, qs)
+}
+
+func (m *MemoryBackend) configure(concurrency int, qs Queues) *MemoryBackend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if concurrency > 0 {
+		m.concurrency = concurrency
+	}
+
+	if len(qs) > 0 {
+		m.queues = qs
+	}
+
+	return m
+}
+
+// WithRetryDelay sets how long failed tasks wait before they are retried, asynq.DefaultRetryDelayFunc by default
+func (m *MemoryBackend) WithRetryDelay(fn asynq.RetryDelayFunc) *MemoryBackend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retryDelay = fn
+
+	return m
+}
+
+// Advance moves the backend clock forward so delayed, scheduled and retried tasks become ready sooner
+func (m *MemoryBackend) Advance(d time.Duration) *MemoryBackend {
+	m.mu.Lock()
+	m.offset += d
+	m.mu.Unlock()
+
+	m.wake()
+
+	return m
+}
+
+func (m *MemoryBackend) now() time.Time {
+	return time.Now().Add(m.offset)
+}
+
+func (m *MemoryBackend) wake() {
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// EnqueueContext adds task to a queue, it implements Enqueuer
+func (m *MemoryBackend) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if strings.TrimSpace(task.Type()) == "" {
+		return nil, fmt.Errorf("task typename cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	t := &memoryTask{info: asynq.TaskInfo{
+		ID:       uuid.NewString(),
+		Queue:    defaultQueue,
+		Type:     task.Type(),
+		Payload:  task.Payload(),
+		MaxRetry: defaultMaxRetry,
+	}}
+
+	processAt := now
+	uniqueTTL := time.Duration(0)
+
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.MaxRetryOpt:
+			t.info.MaxRetry = opt.Value().(int)
+		case asynq.QueueOpt:
+			t.info.Queue = opt.Value().(string)
+		case asynq.TaskIDOpt:
+			t.info.ID = opt.Value().(string)
+		case asynq.TimeoutOpt:
+			t.info.Timeout = opt.Value().(time.Duration)
+		case asynq.DeadlineOpt:
+			t.info.Deadline = opt.Value().(time.Time)
+		case asynq.UniqueOpt:
+			uniqueTTL = opt.Value().(time.Duration)
+		case asynq.ProcessAtOpt:
+			processAt = opt.Value().(time.Time)
+		case asynq.ProcessInOpt:
+			processAt = now.Add(opt.Value().(time.Duration))
+		case asynq.RetentionOpt:
+			t.info.Retention = opt.Value().(time.Duration)
+		case asynq.GroupOpt:
+			t.info.Group = opt.Value().(string)
+		}
+	}
+
+	if _, ok := m.tasks[t.info.ID]; ok {
+		return nil, asynq.ErrTaskIDConflict
+	}
+
+	if uniqueTTL > 0 {
+		t.uniqueKey = fmt.Sprintf("%s:%s:%x", t.info.Queue, t.info.Type, t.info.Payload)
+
+		if expires, ok := m.unique[t.uniqueKey]; ok && expires.After(now) {
+			return nil, asynq.ErrDuplicateTask
+		}
+
+		m.unique[t.uniqueKey] = now.Add(uniqueTTL)
+	}
+
+	m.tasks[t.info.ID] = t
+
+	if processAt.After(now) {
+		t.info.State = asynq.TaskStateScheduled
+		t.info.NextProcessAt = processAt
+		m.scheduled = append(m.scheduled, t)
+	} else {
+		t.info.State = asynq.TaskStatePending
+		t.info.NextProcessAt = now
+		m.pending[t.info.Queue] = append(m.pending[t.info.Queue], t)
+	}
+
+	info := t.info
+
+	m.wake()
+
+	return &info, nil
+}
+
+// Close implements Enqueuer, it is a no-op as the backend is stopped with Shutdown
+func (m *MemoryBackend) Close() error {
+	return nil
+}
+
+// Start starts concurrency workers processing tasks with handler, it implements Backend
+func (m *MemoryBackend) Start(handler asynq.Handler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stop != nil {
+		return errors.New("memory backend is already running")
+	}
+
+	m.handler = handler
+	m.stop = make(chan struct{})
+
+	for i := 0; i < m.concurrency; i++ {
+		m.wg.Add(1)
+
+		go m.work(m.stop)
+	}
+
+	return nil
+}
+
+// Shutdown stops the workers and waits for active tasks to finish, it implements Backend
+func (m *MemoryBackend) Shutdown() {
+	m.mu.Lock()
+	stop := m.stop
+	m.stop = nil
+	m.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+
+	m.wg.Wait()
+}
+
+// Drain processes every ready task with handler in the calling goroutine until none are left
+// and returns how many were processed. Retries that are due immediately are processed as well.
+func (m *MemoryBackend) Drain(ctx context.Context, handler asynq.Handler) int {
+	n := 0
+
+	for ctx.Err() == nil {
+		t := m.next()
+		if t == nil {
+			break
+		}
+
+		m.process(ctx, handler, t)
+
+		n++
+	}
+
+	return n
+}
+
+func (m *MemoryBackend) work(stop chan struct{}) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if t := m.next(); t != nil {
+			m.process(context.Background(), m.handler, t)
+
+			continue
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-m.notify:
+		case <-time.After(memoryPollInterval):
+		}
+	}
+}
+
+// next moves due scheduled tasks to their queues and picks a pending task, queues are picked at random by weight
+func (m *MemoryBackend) next() *memoryTask {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	sort.SliceStable(m.scheduled, func(i, j int) bool {
+		return m.scheduled[i].info.NextProcessAt.Before(m.scheduled[j].info.NextProcessAt)
+	})
+
+	for len(m.scheduled) > 0 && !m.scheduled[0].info.NextProcessAt.After(now) {
+		t := m.scheduled[0]
+		m.scheduled = m.scheduled[1:]
+		t.info.State = asynq.TaskStatePending
+		m.pending[t.info.Queue] = append(m.pending[t.info.Queue], t)
+	}
+
+	names := make([]string, 0, len(m.queues))
+	total := 0
+
+	for name, weight := range m.queues {
+		if len(m.pending[name]) > 0 && weight > 0 {
+			names = append(names, name)
+			total += weight
+		}
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+
+	pick := rand.Intn(total)
+
+	for _, name := range names {
+		if pick -= m.queues[name]; pick < 0 {
+			t := m.pending[name][0]
+			m.pending[name] = m.pending[name][1:]
+			t.info.State = asynq.TaskStateActive
+
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryBackend) process(ctx context.Context, handler asynq.Handler, t *memoryTask) {
+	info := t.info
+	ctx = context.WithValue(ctx, taskKey{}, &info)
+
+	var cancel context.CancelFunc
+
+	switch {
+	case !info.Deadline.IsZero():
+		ctx, cancel = context.WithDeadline(ctx, info.Deadline)
+	case info.Timeout > 0:
+		ctx, cancel = context.WithTimeout(ctx, info.Timeout)
+	default:
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+	}
+
+	defer cancel()
+
+	task := asynq.NewTask(info.Type, info.Payload)
+	err := safeProcess(ctx, handler, task)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	switch {
+	case err == nil:
+		t.info.State = asynq.TaskStateCompleted
+		t.info.CompletedAt = now
+
+		delete(m.tasks, t.info.ID)
+
+		if t.uniqueKey != "" {
+			delete(m.unique, t.uniqueKey)
+		}
+	case errors.Is(err, asynq.SkipRetry) || t.info.Retried >= t.info.MaxRetry:
+		t.info.State = asynq.TaskStateArchived
+		t.info.LastErr = err.Error()
+		t.info.LastFailedAt = now
+	default:
+		// like asynq the delay is computed from the retries before this one
+		t.info.NextProcessAt = now.Add(m.retryDelay(t.info.Retried, err, task))
+		t.info.Retried++
+		t.info.State = asynq.TaskStateRetry
+		t.info.LastErr = err.Error()
+		t.info.LastFailedAt = now
+		m.scheduled = append(m.scheduled, t)
+	}
+}
+
+// queueNames returns the configured queues and the queues tasks were added to
+func (m *MemoryBackend) queueNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := map[string]bool{}
+
+	for name := range m.queues {
+		seen[name] = true
+	}
+
+	for _, t := range m.tasks {
+		seen[t.info.Queue] = true
+	}
+
+	names := make([]string, 0, len(seen))
+
+	for name := range seen {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// archived returns the archived tasks in queue, most recently failed first
+func (m *MemoryBackend) archived(queue string) []*memoryTask {
+	tasks := []*memoryTask{}
+
+	for _, t := range m.tasks {
+		if t.info.Queue == queue && t.info.State == asynq.TaskStateArchived {
+			tasks = append(tasks, t)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].info.LastFailedAt.After(tasks[j].info.LastFailedAt)
+	})
+
+	return tasks
+}
+
+// listArchived returns page of the archived tasks in queue, pages start at 1
+func (m *MemoryBackend) listArchived(queue string, page, size int) []*asynq.TaskInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.archived(queue)
+	infos := []*asynq.TaskInfo{}
+
+	for i := (page - 1) * size; i >= 0 && i < len(tasks) && i < page*size; i++ {
+		info := tasks[i].info
+		infos = append(infos, &info)
+	}
+
+	return infos
+}
+
+// archivedTask returns the archived task id in queue
+func (m *MemoryBackend) archivedTask(queue, id string) (*memoryTask, error) {
+	t, ok := m.tasks[id]
+	if !ok || t.info.Queue != queue || t.info.State != asynq.TaskStateArchived {
+		return nil, asynq.ErrTaskNotFound
+	}
+
+	return t, nil
+}
+
+// runArchived moves archived tasks back to their queue, all archived tasks in queue when no IDs are given
+func (m *MemoryBackend) runArchived(queue string, ids ...string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := []*memoryTask{}
+
+	if len(ids) == 0 {
+		tasks = m.archived(queue)
+	}
+
+	for _, id := range ids {
+		t, err := m.archivedTask(queue, id)
+		if err != nil {
+			return 0, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	for _, t := range tasks {
+		t.info.State = asynq.TaskStatePending
+		t.info.NextProcessAt = m.now()
+		m.pending[queue] = append(m.pending[queue], t)
+	}
+
+	m.wake()
+
+	return len(tasks), nil
+}
+
+// deleteArchived deletes archived tasks, all archived tasks in queue when no IDs are given
+func (m *MemoryBackend) deleteArchived(queue string, ids ...string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := []*memoryTask{}
+
+	if len(ids) == 0 {
+		tasks = m.archived(queue)
+	}
+
+	for _, id := range ids {
+		t, err := m.archivedTask(queue, id)
+		if err != nil {
+			return 0, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	for _, t := range tasks {
+		delete(m.tasks, t.info.ID)
+
+		if t.uniqueKey != "" {
+			delete(m.unique, t.uniqueKey)
+		}
+	}
+
+	return len(tasks), nil
+}
+
+func safeProcess(ctx context.Context, handler asynq.Handler, task *asynq.Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler.ProcessTask(ctx, task)
+}
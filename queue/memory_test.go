@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestMemoryServersAreSeparate(t *testing.T) {
+	cfg, err := ParseRedisURL("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := CreateServer(cfg, 1, nil), CreateServer(cfg, 1, nil)
+	defer a.Shutdown()
+	defer b.Shutdown()
+
+	if a.Backend() == b.Backend() {
+		t.Fatal("servers share a memory backend")
+	}
+
+	for _, q := range []*Queue{a, b} {
+		q.AddHandlerFunc("task", func(context.Context, *asynq.Task) error {
+			return nil
+		})
+	}
+
+	a.Client().Add(asynq.NewTask("task", nil))
+
+	if n := b.Drain(context.Background()); n != 0 {
+		t.Fatalf("other server processed %d tasks", n)
+	}
+
+	if n := a.Drain(context.Background()); n != 1 {
+		t.Fatalf("processed %d tasks, want 1", n)
+	}
+
+	shared := NewClientWith(a.Backend().(Enqueuer))
+	shared.Add(asynq.NewTask("task", nil))
+
+	if n := a.Drain(context.Background()); n != 1 {
+		t.Fatalf("processed %d tasks added through the backend, want 1", n)
+	}
+}
+
+func TestNewClientMemory(t *testing.T) {
+	c := NewClient(RedisConfig{Addr: "memory://"})
+	defer c.Close()
+
+	if _, err := enqueue(context.Background(), c.client, asynq.NewTask("task", nil)); !errors.Is(err, ErrMemoryClient) {
+		t.Fatalf("got %v, want ErrMemoryClient", err)
+	}
+}
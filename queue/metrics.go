@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/khvh/golain/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const meterName = "github.com/khvh/golain/queue"
+
+// Metrics counts and times processed tasks by task type and queue
+type Metrics struct {
+	processed  syncint64.Counter
+	failed     syncint64.Counter
+	inProgress syncint64.UpDownCounter
+	duration   syncfloat64.Histogram
+}
+
+// NewMetrics creates task metrics recorded through mp
+func NewMetrics(mp metric.MeterProvider) *Metrics {
+	meter := mp.Meter(meterName)
+	noop := metric.NewNoopMeter()
+	m := &Metrics{}
+
+	var err error
+
+	if m.processed, err = meter.SyncInt64().Counter("queue.tasks.processed",
+		instrument.WithDescription("The total number of processed tasks")); err != nil {
+		logger.Component("queue").Err(err).Send()
+		m.processed, _ = noop.SyncInt64().Counter("queue.tasks.processed")
+	}
+
+	if m.failed, err = meter.SyncInt64().Counter("queue.tasks.failed",
+		instrument.WithDescription("The total number of times processing failed")); err != nil {
+		logger.Component("queue").Err(err).Send()
+		m.failed, _ = noop.SyncInt64().Counter("queue.tasks.failed")
+	}
+
+	if m.inProgress, err = meter.SyncInt64().UpDownCounter("queue.tasks.in_progress",
+		instrument.WithDescription("The number of tasks currently being processed")); err != nil {
+		logger.Component("queue").Err(err).Send()
+		m.inProgress, _ = noop.SyncInt64().UpDownCounter("queue.tasks.in_progress")
+	}
+
+	if m.duration, err = meter.SyncFloat64().Histogram("queue.task.duration",
+		instrument.WithDescription("How long processing a task took"), instrument.WithUnit(unit.Milliseconds)); err != nil {
+		logger.Component("queue").Err(err).Send()
+		m.duration, _ = noop.SyncFloat64().Histogram("queue.task.duration")
+	}
+
+	return m
+}
+
+func (m *Metrics) process(ctx context.Context, next asynq.Handler, t *asynq.Task) error {
+	attrs := []attribute.KeyValue{taskTypeKey.String(t.Type())}
+
+	if queue, ok := QueueName(ctx); ok {
+		attrs = append(attrs, semconv.MessagingDestinationKey.String(queue))
+	}
+
+	start := time.Now()
+
+	m.inProgress.Add(ctx, 1, attrs...)
+	err := next.ProcessTask(ctx, t)
+	m.inProgress.Add(ctx, -1, attrs...)
+
+	m.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+
+	if err != nil {
+		m.failed.Add(ctx, 1, attrs...)
+	}
+
+	m.processed.Add(ctx, 1, attrs...)
+
+	return err
+}
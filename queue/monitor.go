@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/hibiken/asynqmon"
+	"github.com/khvh/golain/logger"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	monitorRoot        = "/monitoring/tasks"
+	deadLetterPath     = "/dead_letters"
+	deadLetterPageSize = 30
+)
+
+// schedulerEntry is an entry in the format of the asynqmon API
+type schedulerEntry struct {
+	ID            string   `json:"id"`
+	Spec          string   `json:"spec"`
+	TaskType      string   `json:"task_type"`
+	TaskPayload   string   `json:"task_payload"`
+	Opts          []string `json:"options"`
+	NextEnqueueAt string   `json:"next_enqueue_at"`
+	PrevEnqueueAt string   `json:"prev_enqueue_at,omitempty"`
+}
+
+// withDeadLetters serves the dead letter API under root, other requests are passed to next
+//
+//	GET    <root>/dead_letters                    queues
+//	GET    <root>/dead_letters/{queue}?page=&size= archived tasks
+//	GET    <root>/dead_letters/{queue}/export     archived tasks as JSON lines
+//	POST   <root>/dead_letters/{queue}/retry      retry all archived tasks
+//	DELETE <root>/dead_letters/{queue}            delete all archived tasks
+//	POST   <root>/dead_letters/{queue}/{id}/retry retry an archived task
+//	DELETE <root>/dead_letters/{queue}/{id}       delete an archived task
+func (q *Queue) withDeadLetters(root string, next http.Handler) http.Handler {
+	base := root + deadLetterPath
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+base, func(w http.ResponseWriter, r *http.Request) {
+		queues, err := q.Inspector().Queues()
+
+		writeJSON(w, map[string]any{"queues": queues}, err)
+	})
+
+	mux.HandleFunc("GET "+base+"/{queue}", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		size, err := strconv.Atoi(r.URL.Query().Get("size"))
+		if err != nil || size <= 0 {
+			size = deadLetterPageSize
+		}
+
+		tasks, err := q.Inspector().DeadLetters(r.PathValue("queue"), page, size)
+
+		writeJSON(w, map[string]any{"tasks": tasks}, err)
+	})
+
+	mux.HandleFunc("GET "+base+"/{queue}/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+r.PathValue("queue")+".jsonl\"")
+
+		if _, err := q.Inspector().Export(r.PathValue("queue"), w); err != nil {
+			logger.Component("queue").Err(err).Msg("Exporting dead letters failed")
+		}
+	})
+
+	mux.HandleFunc("POST "+base+"/{queue}/retry", func(w http.ResponseWriter, r *http.Request) {
+		n, err := q.Inspector().RetryAll(r.PathValue("queue"))
+
+		writeJSON(w, map[string]any{"count": n}, err)
+	})
+
+	mux.HandleFunc("DELETE "+base+"/{queue}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := q.Inspector().DeleteAll(r.PathValue("queue"))
+
+		writeJSON(w, map[string]any{"count": n}, err)
+	})
+
+	mux.HandleFunc("POST "+base+"/{queue}/{id}/retry", func(w http.ResponseWriter, r *http.Request) {
+		err := q.Inspector().Retry(r.PathValue("queue"), r.PathValue("id"))
+
+		writeJSON(w, map[string]any{"id": r.PathValue("id")}, err)
+	})
+
+	mux.HandleFunc("DELETE "+base+"/{queue}/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := q.Inspector().Delete(r.PathValue("queue"), r.PathValue("id"))
+
+		writeJSON(w, map[string]any{"id": r.PathValue("id")}, err)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isDeadLetterPath(base, r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func isDeadLetterPath(base string, r *http.Request) bool {
+	return r.URL.Path == base || strings.HasPrefix(r.URL.Path, base+"/")
+}
+
+// writeJSON writes v, or err with a status code matching it
+func writeJSON(w http.ResponseWriter, v any, err error) {
+	code := http.StatusOK
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errUnauthorized):
+		code = http.StatusUnauthorized
+	case errors.Is(err, errForbidden):
+		code = http.StatusForbidden
+	case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrNoInspector):
+		code = http.StatusNotImplemented
+	default:
+		code = http.StatusInternalServerError
+	}
+
+	if err != nil {
+		v = map[string]string{"error": err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Err(err).Send()
+	}
+}
+
+// withSchedulerEntries serves the entries of the queue scheduler on the asynqmon scheduler API
+func (q *Queue) withSchedulerEntries(mon *asynqmon.HTTPHandler) http.Handler {
+	path := mon.RootPath() + "/api/scheduler_entries"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != path || q.scheduler == nil {
+			mon.ServeHTTP(w, r)
+
+			return
+		}
+
+		entries := []*schedulerEntry{}
+
+		for _, e := range q.scheduler.Entries() {
+			entry := &schedulerEntry{
+				ID:            e.ID,
+				Spec:          e.Spec,
+				TaskType:      e.Task.Type(),
+				TaskPayload:   string(e.Task.Payload()),
+				Opts:          []string{},
+				NextEnqueueAt: e.Next().Format(time.RFC3339),
+			}
+
+			for _, o := range e.Opts {
+				entry.Opts = append(entry.Opts, o.String())
+			}
+
+			if prev := e.Prev(); !prev.IsZero() {
+				entry.PrevEnqueueAt = prev.Format(time.RFC3339)
+			}
+
+			entries = append(entries, entry)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(map[string]any{"entries": entries}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
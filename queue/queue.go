@@ -3,61 +3,41 @@ package queue
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/hibiken/asynqmon"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/khvh/golain/logger"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	processedCounter = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "processed_tasks_total",
-			Help: "The total number of processed tasks",
-		},
-		[]string{"task_type"},
-	)
-
-	failedCounter = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "failed_tasks_total",
-			Help: "The total number of times processing failed",
-		},
-		[]string{"task_type"},
-	)
-
-	inProgressGauge = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "in_progress_tasks",
-			Help: "The number of tasks currently being processed",
-		},
-		[]string{"task_type"},
-	)
-)
-
-type logger struct {
+type asynqLogger struct {
 }
 
-func (c logger) Debug(args ...interface{}) {
-	log.Trace().Str("from", "asynq").Msg(fmt.Sprint(args...))
+func (c asynqLogger) Debug(args ...interface{}) {
+	logger.Component("asynq").Trace().Msg(fmt.Sprint(args...))
 }
 
-func (c logger) Info(args ...interface{}) {
-	log.Info().Str("from", "asynq").Msg(fmt.Sprint(args...))
+func (c asynqLogger) Info(args ...interface{}) {
+	logger.Component("asynq").Info().Msg(fmt.Sprint(args...))
 }
 
-func (c logger) Warn(args ...interface{}) {
-	log.Warn().Str("from", "asynq").Msg(fmt.Sprint(args...))
+func (c asynqLogger) Warn(args ...interface{}) {
+	logger.Component("asynq").Warn().Msg(fmt.Sprint(args...))
 }
 
-func (c logger) Error(args ...interface{}) {
-	log.Error().Str("from", "asynq").Msg(fmt.Sprint(args...))
+func (c asynqLogger) Error(args ...interface{}) {
+	logger.Component("asynq").Error().Msg(fmt.Sprint(args...))
 }
 
-func (c logger) Fatal(args ...interface{}) {
-	log.Fatal().Str("from", "asynq").Msg(fmt.Sprint(args...))
+func (c asynqLogger) Fatal(args ...interface{}) {
+	logger.Component("asynq").Fatal().Msg(fmt.Sprint(args...))
 }
 
 // Queues represents queues config for Asynq
@@ -65,57 +45,175 @@ type Queues map[string]int
 
 // Queue holds Asynq server things
 type Queue struct {
-	srv *asynq.Server
-	mux *asynq.ServeMux
+	name         string
+	redis        RedisConfig
+	backend      Backend
+	mux          *asynq.ServeMux
+	enqueuer     Enqueuer
+	locker       Locker
+	scheduler    *Scheduler
+	metrics      *Metrics
+	policies     map[string]RetryPolicy
+	onDeadLetter []DeadLetterFunc
+	inspector    *Inspector
+	monitor      monitor
+	closers      []io.Closer
+}
+
+// CreateServer creates a new Asynq server processing qs with concurrency workers, a memory:// address
+// processes tasks in-process without Redis in a backend of its own. A concurrency of 0 uses the backend default.
+func CreateServer(cfg RedisConfig, concurrency int, qs Queues) *Queue {
+	var q *Queue
+
+	// the delay is looked up on every retry so policies added later are used
+	retryDelay := func(n int, err error, t *asynq.Task) time.Duration {
+		return q.retryDelay(n, err, t)
+	}
+
+	if cfg.IsMemory() {
+		q = NewQueue(NewMemoryBackend(10, Queues{defaultQueue: 1}).configure(concurrency, qs).WithRetryDelay(retryDelay))
+	} else {
+		client := asynq.NewClient(cfg.ConnOpt())
+		rdb := cfg.Client()
+
+		q = NewQueue(asynq.NewServer(
+			cfg.ConnOpt(),
+			asynq.Config{
+				Concurrency:    concurrency,
+				Queues:         qs,
+				RetryDelayFunc: retryDelay,
+				Logger:         asynqLogger{},
+			},
+		)).
+			WithEnqueuer(client).
+			WithLocker(NewRedisLocker(rdb))
+
+		q.closers = append(q.closers, client, rdb)
+	}
+
+	q.redis = cfg
+
+	return q
+}
+
+// NewQueue creates a queue processing tasks with backend
+func NewQueue(backend Backend) *Queue {
+	q := &Queue{
+		backend:  backend,
+		mux:      asynq.NewServeMux(),
+		locker:   NewLocalLocker(),
+		policies: map[string]RetryPolicy{},
+	}
+
+	q.mux.Use(tracing, logging, q.measure, q.deadLetters)
+
+	if e, ok := backend.(Enqueuer); ok {
+		q.enqueuer = e
+	}
+
+	return q
+}
+
+// WithEnqueuer sets how the scheduler adds tasks, backends that implement Enqueuer are used by default
+func (q *Queue) WithEnqueuer(e Enqueuer) *Queue {
+	q.enqueuer = e
+
+	return q
+}
+
+// WithName names the queue, usually after the service. Schedulers of queues with the same name elect one
+// leader, so every service sharing a Redis runs its own schedules.
+func (q *Queue) WithName(name string) *Queue {
+	q.name = name
+
+	if q.scheduler != nil {
+		q.scheduler.WithName(name)
+	}
+
+	return q
+}
+
+// WithLocker sets how the scheduler leader is elected, by default only the scheduler of this queue is coordinated
+func (q *Queue) WithLocker(l Locker) *Queue {
+	q.locker = l
+
+	return q
 }
 
-var queueInstance *Queue
+// WithMetrics records task metrics through mp, it has to be called before Run
+func (q *Queue) WithMetrics(mp metric.MeterProvider) *Queue {
+	q.metrics = NewMetrics(mp)
 
-func metrics(next asynq.Handler) asynq.Handler {
+	return q
+}
+
+func (q *Queue) measure(next asynq.Handler) asynq.Handler {
 	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
-		inProgressGauge.WithLabelValues(t.Type()).Inc()
-		err := next.ProcessTask(ctx, t)
-		inProgressGauge.WithLabelValues(t.Type()).Dec()
-		if err != nil {
-			failedCounter.WithLabelValues(t.Type()).Inc()
+		if q.metrics == nil {
+			return next.ProcessTask(ctx, t)
 		}
-		processedCounter.WithLabelValues(t.Type()).Inc()
-		return err
+
+		return q.metrics.process(ctx, next, t)
 	})
 }
 
-// CreateServer creates a new Asynq server
-func CreateServer(redisAddress string, concurrency int, qs Queues) *Queue {
-	if queueInstance == nil {
-		srv := asynq.NewServer(
-			asynq.RedisClientOpt{Addr: redisAddress},
-			asynq.Config{
-				Concurrency: concurrency,
-				Queues:      qs,
-				Logger:      logger{},
-			},
-		)
+// Client returns a client adding tasks to the queue, its connection is closed with the queue
+func (q *Queue) Client() *Client {
+	return NewClientWith(q.enqueuer)
+}
+
+// Inspector returns an inspector for the archived tasks of the queue, it is closed with the queue
+func (q *Queue) Inspector() *Inspector {
+	if q.inspector == nil {
+		m, ok := q.backend.(*MemoryBackend)
+
+		switch {
+		case ok:
+			q.inspector = &Inspector{memory: m}
+		case q.redis.Addr != "" || len(q.redis.Addrs) > 0:
+			q.inspector = NewInspector(q.redis)
+			q.closers = append(q.closers, q.inspector)
+		default:
+			q.inspector = &Inspector{}
+		}
+	}
 
-		mux := asynq.NewServeMux()
+	return q.inspector
+}
 
-		queueInstance = &Queue{srv, mux}
+// Scheduler returns the scheduler for periodic tasks, it starts and stops with the queue
+func (q *Queue) Scheduler() *Scheduler {
+	if q.scheduler == nil {
+		q.scheduler = NewScheduler(q.enqueuer, q.locker).WithName(q.name)
 	}
 
-	return queueInstance
+	return q.scheduler
+}
+
+// Backend returns the backend processing tasks
+func (q *Queue) Backend() Backend {
+	return q.backend
 }
 
-// MountMonitor mounts asynqmon
-func (q *Queue) MountMonitor(redisAddress, redisPw string) (*Queue, *asynqmon.HTTPHandler) {
+// MountMonitor returns the handler to mount at /monitoring/tasks, it serves asynqmon connected with the Redis
+// config of the queue and the dead letter API, protected as set with WithMonitor. Scheduler entries are listed
+// in the monitor, the memory backend only has the API.
+func (q *Queue) MountMonitor() (*Queue, http.Handler) {
+	if q.redis.IsMemory() || q.redis.Addr == "" && len(q.redis.Addrs) == 0 {
+		return q, q.protect(monitorRoot, q.withDeadLetters(monitorRoot, http.NotFoundHandler()))
+	}
+
+	if len(q.monitor.auth) == 0 && len(q.monitor.allowIPs) == 0 {
+		logger.Component("queue").Warn().Msgf("Task monitor at %s is not protected, see queue.MonitorOptions", monitorRoot)
+	}
+
 	mon := asynqmon.New(asynqmon.Options{
-		RootPath: "/monitoring/tasks",
-		RedisConnOpt: asynq.RedisClientOpt{
-			Addr:     redisAddress,
-			Password: redisPw,
-			DB:       0,
-		},
+		RootPath:     monitorRoot,
+		RedisConnOpt: q.redis.ConnOpt(),
+		ReadOnly:     q.monitor.readOnly,
 	})
 
-	return q, mon
+	return q, q.protect(monitorRoot, q.withDeadLetters(monitorRoot, q.withSchedulerEntries(mon)))
 }
 
 // HandlerFunc ...
@@ -144,37 +242,111 @@ func (q *Queue) AddHandler(pattern string, handler asynq.Handler) *Queue {
 	return q
 }
 
+// Run starts processing tasks in the background, stop it with Shutdown
 func (q *Queue) Run() {
-	go func() {
-		if err := q.srv.Run(q.mux); err != nil {
-			log.Fatal().Err(err)
+	if err := q.backend.Start(q.mux); err != nil {
+		log.Err(err).Send()
+	}
+
+	if q.scheduler != nil {
+		if err := q.scheduler.Start(); err != nil {
+			log.Err(err).Send()
+		}
+	}
+}
+
+// Shutdown stops fetching new tasks, waits for active tasks to finish and closes the Redis connections
+func (q *Queue) Shutdown() {
+	if q.scheduler != nil {
+		q.scheduler.Shutdown()
+	}
+
+	q.backend.Shutdown()
+
+	for _, c := range q.closers {
+		if err := c.Close(); err != nil {
+			log.Err(err).Send()
 		}
-	}()
+	}
+
+	q.closers = nil
+}
+
+// Drain processes every ready task in the calling goroutine and returns how many ran,
+// it is meant for tests and only works with the memory backend
+func (q *Queue) Drain(ctx context.Context) int {
+	m, ok := q.backend.(*MemoryBackend)
+	if !ok {
+		return 0
+	}
+
+	return m.Drain(ctx, q.mux)
+}
+
+// enqueue adds task with e in a producer span, the payload carries the trace context to the worker
+func enqueue(ctx context.Context, e Enqueuer, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	ctx, span := startSend(ctx, task.Type())
+	defer span.End()
+
+	return send(ctx, span, e, inject(ctx, task, opts), opts...)
+}
+
+// send adds task with e and records the result in span
+func send(ctx context.Context, span trace.Span, e Enqueuer, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	info, err := e.EnqueueContext(ctx, task, opts...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	span.SetAttributes(
+		semconv.MessagingMessageIDKey.String(info.ID),
+		semconv.MessagingDestinationKey.String(info.Queue),
+	)
+
+	return info, nil
 }
 
 // Client ...
 type Client struct {
-	client *asynq.Client
+	client Enqueuer
 }
 
-var clientInstance *Client
-
-// NewClient ...
-func NewClient(redisAddress string) *Client {
-	if clientInstance == nil {
-		clientInstance = &Client{
-			client: asynq.NewClient(asynq.RedisClientOpt{Addr: redisAddress}),
-		}
+// NewClient creates a client for the queue described by cfg, close it when done with it. A memory://
+// backend is private to its queue, its tasks are added with Queue.Client or NewClientWith(q.Backend()).
+func NewClient(cfg RedisConfig) *Client {
+	if cfg.IsMemory() {
+		return NewClientWith(noEnqueuer{ErrMemoryClient})
 	}
 
-	return clientInstance
+	return NewClientWith(asynq.NewClient(cfg.ConnOpt()))
+}
+
+// NewClientWith creates a client adding tasks with e
+func NewClientWith(e Enqueuer) *Client {
+	return &Client{client: e}
+}
+
+// Close closes the connection of the client
+func (c *Client) Close() error {
+	return c.client.Close()
 }
 
 // Add a new task to queue
 func (c *Client) Add(task *asynq.Task, opts ...asynq.Option) *Client {
-	info, err := c.client.Enqueue(task, opts...)
+	return c.AddContext(context.Background(), task, opts...)
+}
+
+// AddContext adds a new task to queue, the trace context in ctx is passed on to the worker. Unique tasks
+// don't carry it, their payload must stay as it is.
+func (c *Client) AddContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) *Client {
+	info, err := enqueue(ctx, c.client, task, opts...)
 	if err != nil {
 		log.Err(err).Send()
+
+		return c
 	}
 
 	log.Trace().Msgf("Added task [%s] to [%s]", info.ID, info.Queue)
@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hibiken/asynq"
+)
+
+// RedisConfig describes how to connect to Redis, it is used by the server, client, monitor and scheduler.
+// Setting MasterName connects through Sentinel and Cluster connects to a Redis Cluster, otherwise Addr
+// is used. An Addr of memory:// selects the in-memory backend.
+type RedisConfig struct {
+	Addr             string
+	Addrs            []string
+	Username         string
+	Password         string
+	DB               int
+	TLS              *tls.Config
+	MasterName       string
+	SentinelPassword string
+	Cluster          bool
+}
+
+// ParseRedisURL parses a connection URL into a RedisConfig, supported forms are
+//
+//	host:port
+//	memory://
+//	redis://[[user]:password@]host:port[/db]
+//	rediss://[[user]:password@]host:port[/db]
+//	redis-sentinel://[[user]:password@]host:port[,host:port...]/master[?db=N&sentinel_password=...]
+//	redis-cluster://[[user]:password@]host:port[,host:port...]
+//
+// The tls=true query parameter enables TLS for any scheme, rediss:// always uses TLS.
+func ParseRedisURL(raw string) (RedisConfig, error) {
+	cfg := RedisConfig{}
+
+	if IsMemoryURL(raw) {
+		cfg.Addr = raw
+
+		return cfg, nil
+	}
+
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		cfg.Addr = raw
+
+		return cfg, nil
+	}
+
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	hosts, path, _ := strings.Cut(rest, "/")
+
+	if i := strings.LastIndex(hosts, "@"); i >= 0 {
+		u, err := url.Parse("redis://" + hosts[:i+1] + "localhost")
+		if err != nil {
+			return cfg, fmt.Errorf("invalid redis URL credentials: %w", err)
+		}
+
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+		hosts = hosts[i+1:]
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid redis URL query: %w", err)
+	}
+
+	addrs := strings.Split(hosts, ",")
+
+	switch scheme {
+	case "redis", "rediss":
+		cfg.Addr = addrs[0]
+
+		if !strings.Contains(cfg.Addr, ":") {
+			cfg.Addr += ":6379"
+		}
+
+		if path != "" {
+			if cfg.DB, err = strconv.Atoi(path); err != nil {
+				return cfg, fmt.Errorf("invalid redis database %q", path)
+			}
+		}
+	case "redis-sentinel":
+		cfg.Addrs = addrs
+		cfg.MasterName = query.Get("master")
+		cfg.SentinelPassword = query.Get("sentinel_password")
+
+		if path != "" {
+			cfg.MasterName = path
+		}
+
+		if cfg.MasterName == "" {
+			return cfg, fmt.Errorf("redis sentinel URL needs a master name")
+		}
+	case "redis-cluster":
+		cfg.Addrs = addrs
+		cfg.Cluster = true
+	default:
+		return cfg, fmt.Errorf("unsupported redis URL scheme %q", scheme)
+	}
+
+	if db := query.Get("db"); db != "" {
+		if cfg.DB, err = strconv.Atoi(db); err != nil {
+			return cfg, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	if scheme == "rediss" || query.Get("tls") == "true" {
+		cfg.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return cfg, nil
+}
+
+// IsMemory reports whether the config selects the in-memory backend
+func (c RedisConfig) IsMemory() bool {
+	return IsMemoryURL(c.Addr)
+}
+
+// ConnOpt returns the asynq connection options for the config
+func (c RedisConfig) ConnOpt() asynq.RedisConnOpt {
+	switch {
+	case c.MasterName != "":
+		return asynq.RedisFailoverClientOpt{
+			MasterName:       c.MasterName,
+			SentinelAddrs:    c.addrs(),
+			SentinelPassword: c.SentinelPassword,
+			Username:         c.Username,
+			Password:         c.Password,
+			DB:               c.DB,
+			TLSConfig:        c.TLS,
+		}
+	case c.Cluster:
+		return asynq.RedisClusterClientOpt{
+			Addrs:     c.addrs(),
+			Username:  c.Username,
+			Password:  c.Password,
+			TLSConfig: c.TLS,
+		}
+	default:
+		return asynq.RedisClientOpt{
+			Addr:      c.Addr,
+			Username:  c.Username,
+			Password:  c.Password,
+			DB:        c.DB,
+			TLSConfig: c.TLS,
+		}
+	}
+}
+
+// Client creates a Redis client for the config
+func (c RedisConfig) Client() redis.UniversalClient {
+	return c.ConnOpt().MakeRedisClient().(redis.UniversalClient)
+}
+
+func (c RedisConfig) addrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+
+	return []string{c.Addr}
+}
@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	defaultRetryBase   = time.Second
+	defaultRetryFactor = 2
+	defaultRetryMax    = 24 * time.Hour
+)
+
+// RetryPolicy sets how failed tasks of a type are retried. The n-th retry waits Base * Factor^n, at most Max,
+// plus a random Jitter. MaxRetry caps the retries of the type, tasks enqueued with a lower asynq.MaxRetry stop
+// earlier and 0 keeps the MaxRetry the task was enqueued with.
+type RetryPolicy struct {
+	MaxRetry int
+	Base     time.Duration
+	Factor   float64
+	Max      time.Duration
+	Jitter   time.Duration
+}
+
+// Delay returns how long to wait before retrying a task that has been retried n times
+func (p RetryPolicy) Delay(n int) time.Duration {
+	base, factor, max := p.Base, p.Factor, p.Max
+
+	if base <= 0 {
+		base = defaultRetryBase
+	}
+
+	if factor < 1 {
+		factor = defaultRetryFactor
+	}
+
+	if max <= 0 {
+		max = defaultRetryMax
+	}
+
+	d := max
+
+	if f := float64(base) * math.Pow(factor, float64(n)); f < float64(max) {
+		d = time.Duration(f)
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+
+	return d
+}
+
+// DeadLetterFunc is called when a task failed for the last time and is archived
+type DeadLetterFunc func(ctx context.Context, dl *DeadLetter) error
+
+// WithRetryPolicy sets how failed tasks of taskType are retried, it has to be called before Run
+func (q *Queue) WithRetryPolicy(taskType string, p RetryPolicy) *Queue {
+	q.policies[taskType] = p
+
+	return q
+}
+
+// OnDeadLetter registers hooks called when a task failed for the last time, e.g. to alert or to keep the
+// failure elsewhere. Hooks have to be registered before Run.
+func (q *Queue) OnDeadLetter(hooks ...DeadLetterFunc) *Queue {
+	q.onDeadLetter = append(q.onDeadLetter, hooks...)
+
+	return q
+}
+
+// retryDelay uses the retry policy of the task type, asynq.DefaultRetryDelayFunc otherwise
+func (q *Queue) retryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if p, ok := q.policies[t.Type()]; ok {
+		return p.Delay(n)
+	}
+
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
+
+// deadLetters is a worker middleware applying the MaxRetry of retry policies and calling the dead letter hooks
+func (q *Queue) deadLetters(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		// panics are turned into errors here rather than by the backend so the hooks see them
+		err := safeProcess(ctx, next, t)
+		if err == nil {
+			return nil
+		}
+
+		retried, _ := RetryCount(ctx)
+
+		maxRetry, ok := MaxRetry(ctx)
+		if !ok {
+			return err
+		}
+
+		if p, ok := q.policies[t.Type()]; ok && p.MaxRetry > 0 && p.MaxRetry < maxRetry {
+			maxRetry = p.MaxRetry
+
+			if retried >= maxRetry && !errors.Is(err, asynq.SkipRetry) {
+				err = fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+			}
+		}
+
+		dead := errors.Is(err, asynq.SkipRetry) || retried >= maxRetry
+
+		if dead && len(q.onDeadLetter) > 0 {
+			// hooks get the payload that was enqueued, without the trace context
+			_, payload := unwrap(t.Payload())
+
+			dl := &DeadLetter{
+				Type:     t.Type(),
+				Payload:  payload,
+				Error:    err.Error(),
+				Retried:  retried,
+				MaxRetry: maxRetry,
+				FailedAt: time.Now(),
+			}
+
+			dl.ID, _ = TaskID(ctx)
+			dl.Queue, _ = QueueName(ctx)
+
+			for _, hook := range q.onDeadLetter {
+				if hookErr := hook(ctx, dl); hookErr != nil {
+					Logger(ctx).Err(hookErr).Str("from", "queue").Msg("Dead letter hook failed")
+				}
+			}
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel"
+)
+
+type item struct {
+	ID string `json:"id"`
+}
+
+func TestDeadLetterPayload(t *testing.T) {
+	recordSpans(t)
+
+	q := NewQueue(NewMemoryBackend(1, Queues{defaultQueue: 1}))
+	defer q.Shutdown()
+
+	var payloads []string
+
+	q.OnDeadLetter(func(ctx context.Context, dl *DeadLetter) error {
+		payloads = append(payloads, string(dl.Payload))
+
+		return nil
+	})
+
+	task := NewTask[item]("item")
+
+	Handle(q, task, func(ctx context.Context, payload item) error {
+		return fmt.Errorf("failed %s: %w", payload.ID, asynq.SkipRetry)
+	})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+
+	if _, err := Enqueue(ctx, q.Client(), task, item{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	q.Drain(context.Background())
+
+	if len(payloads) != 1 || payloads[0] != `{"id":"1"}` {
+		t.Fatalf("dead letter hooks got %q", payloads)
+	}
+
+	archived, err := q.Inspector().DeadLetters(defaultQueue, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(archived) != 1 || string(archived[0].Payload) != `{"id":"1"}` {
+		t.Fatalf("archived dead letters are %v", archived)
+	}
+
+	// the middleware unwraps on its own, without the tracing middleware in front of it
+	payloads = nil
+
+	ctx = context.WithValue(context.Background(), taskKey{}, &asynq.TaskInfo{Queue: defaultQueue})
+	failing := asynq.HandlerFunc(func(context.Context, *asynq.Task) error {
+		return errors.New("failed")
+	})
+
+	wrapped := asynq.NewTask("item", wrap(map[string]string{"traceparent": "00-0"}, []byte(`{"id":"2"}`)))
+
+	if err := q.deadLetters(failing).ProcessTask(ctx, wrapped); err == nil {
+		t.Fatal("expected the handler error")
+	}
+
+	if len(payloads) != 1 || payloads[0] != `{"id":"2"}` {
+		t.Fatalf("dead letter hooks got %q", payloads)
+	}
+}
@@ -0,0 +1,315 @@
+package queue
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/khvh/golain/logger"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	schedulerLockKey = "golain:scheduler:leader"
+	schedulerLockTTL = 15 * time.Second
+)
+
+// Scheduler enqueues tasks on cron schedules or fixed intervals. When several replicas run
+// a scheduler only the one holding the leader lock enqueues, the others stand by.
+type Scheduler struct {
+	mu       sync.Mutex
+	enqueuer Enqueuer
+	locker   Locker
+	key      string
+	owner    string
+	leader   atomic.Bool
+	entries  []*Entry
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Entry is a task enqueued on a schedule
+type Entry struct {
+	ID       string
+	Spec     string
+	Task     *asynq.Task
+	Opts     []asynq.Option
+	Location *time.Location
+	Jitter   time.Duration
+
+	mu       sync.Mutex
+	schedule cron.Schedule
+	next     time.Time
+	prev     time.Time
+}
+
+// NewScheduler creates a scheduler adding tasks with e, l elects the leader
+func NewScheduler(e Enqueuer, l Locker) *Scheduler {
+	return &Scheduler{
+		enqueuer: e,
+		locker:   l,
+		key:      schedulerLockKey,
+		owner:    uuid.NewString(),
+	}
+}
+
+// WithName sets the name the leader lock is taken for, schedulers with the same name elect one leader. It has
+// to be called before Start.
+func (s *Scheduler) WithName(name string) *Scheduler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = schedulerLockKey
+
+	if name != "" {
+		s.key = "golain:scheduler:" + name + ":leader"
+	}
+
+	return s
+}
+
+// Cron registers task to be enqueued on a cron spec, e.g. "0 3 * * *", "@daily" or "CRON_TZ=Europe/Tallinn 0 3 * * *"
+func (s *Scheduler) Cron(spec string, task *asynq.Task, opts ...asynq.Option) (*Entry, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron spec %q: %w", spec, err)
+	}
+
+	return s.add(spec, schedule, task, opts), nil
+}
+
+// Every registers task to be enqueued every d, d is rounded to whole seconds
+func (s *Scheduler) Every(d time.Duration, task *asynq.Task, opts ...asynq.Option) *Entry {
+	return s.add("@every "+d.String(), cron.Every(d), task, opts)
+}
+
+// Schedule registers a typed task to be enqueued with payload on a cron spec
+func Schedule[P any](s *Scheduler, spec string, t *Task[P], payload P, opts ...asynq.Option) (*Entry, error) {
+	task, err := t.Encode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Cron(spec, task, append(append([]asynq.Option{}, t.Opts...), opts...)...)
+}
+
+func (s *Scheduler) add(spec string, schedule cron.Schedule, task *asynq.Task, opts []asynq.Option) *Entry {
+	// the ID only depends on the entry so it is the same on every replica
+	sum := sha1.Sum([]byte(spec + "\x00" + task.Type() + "\x00" + string(task.Payload())))
+
+	e := &Entry{
+		ID:       hex.EncodeToString(sum[:8]),
+		Spec:     spec,
+		Task:     task,
+		Opts:     opts,
+		Location: time.Local,
+		schedule: schedule,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+
+	if s.stop != nil {
+		s.run(e, s.stop)
+	}
+
+	return e
+}
+
+// In sets the time zone cron specs without CRON_TZ are evaluated in, time.Local by default
+func (e *Entry) In(loc *time.Location) *Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Location = loc
+
+	return e
+}
+
+// WithJitter delays each run by a random duration up to d, to spread load across entries
+func (e *Entry) WithJitter(d time.Duration) *Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Jitter = d
+
+	return e
+}
+
+// Next returns when the entry runs next, zero before the scheduler is started
+func (e *Entry) Next() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.next
+}
+
+// Prev returns when the entry last ran, zero if it hasn't
+func (e *Entry) Prev() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.prev
+}
+
+// Entries returns the registered entries ordered by their next run
+func (s *Scheduler) Entries() []*Entry {
+	s.mu.Lock()
+	entries := append([]*Entry{}, s.entries...)
+	s.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Next().Before(entries[j].Next())
+	})
+
+	return entries
+}
+
+// IsLeader reports whether this scheduler currently enqueues tasks
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// Start starts enqueuing tasks in the background
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return errors.New("scheduler is already running")
+	}
+
+	s.stop = make(chan struct{})
+
+	s.elect()
+
+	s.wg.Add(1)
+
+	go s.campaign(s.stop)
+
+	for _, e := range s.entries {
+		s.run(e, s.stop)
+	}
+
+	return nil
+}
+
+// Shutdown stops the scheduler and gives up leadership
+func (s *Scheduler) Shutdown() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+
+	s.wg.Wait()
+
+	if s.leader.Swap(false) {
+		if err := s.locker.Unlock(context.Background(), s.key, s.owner); err != nil {
+			log.Err(err).Send()
+		}
+	}
+}
+
+// elect tries to acquire or extend the leader lock
+func (s *Scheduler) elect() {
+	ctx, cancel := context.WithTimeout(context.Background(), schedulerLockTTL/3)
+	defer cancel()
+
+	ok, err := s.locker.Lock(ctx, s.key, s.owner, schedulerLockTTL)
+	if err != nil {
+		logger.Component("scheduler").Err(err).Send()
+	}
+
+	if s.leader.Swap(ok) != ok {
+		logger.Component("scheduler").Debug().Bool("leader", ok).Msg("Scheduler leadership changed")
+	}
+}
+
+func (s *Scheduler) campaign(stop chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(schedulerLockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.elect()
+		}
+	}
+}
+
+func (s *Scheduler) run(e *Entry, stop chan struct{}) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			e.mu.Lock()
+			next := e.schedule.Next(time.Now().In(e.Location))
+			delay := time.Until(next)
+
+			if e.Jitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(e.Jitter)))
+			}
+
+			e.next = next
+			e.mu.Unlock()
+
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-stop:
+				timer.Stop()
+
+				return
+			case <-timer.C:
+			}
+
+			if s.IsLeader() {
+				s.enqueue(e, next)
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) enqueue(e *Entry, at time.Time) {
+	e.mu.Lock()
+	e.prev = time.Now()
+	e.mu.Unlock()
+
+	// a deterministic task ID keeps a new leader from enqueuing a run the old one already did
+	opts := append([]asynq.Option{asynq.TaskID(fmt.Sprintf("%s:%d", e.ID, at.Unix()))}, e.Opts...)
+
+	info, err := enqueue(context.Background(), s.enqueuer, e.Task, opts...)
+
+	switch {
+	case errors.Is(err, asynq.ErrTaskIDConflict):
+		logger.Component("scheduler").Trace().Str("entry", e.ID).Msg("Run already enqueued")
+	case err != nil:
+		logger.Component("scheduler").Err(err).Str("entry", e.ID).Send()
+	default:
+		logger.Component("scheduler").Trace().Str("entry", e.ID).Msgf("Enqueued task [%s] to [%s]", info.ID, info.Queue)
+	}
+}
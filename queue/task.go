@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/khvh/golain/validate"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Codec marshals task payloads
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+// Marshal implements Codec
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+// Marshal implements Codec
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal implements Codec
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+var (
+	// JSON encodes payloads as JSON, it is the default codec
+	JSON Codec = jsonCodec{}
+	// MsgPack encodes payloads as MessagePack
+	MsgPack Codec = msgpackCodec{}
+)
+
+// PayloadError is returned when a payload can't be encoded, decoded or fails validation
+type PayloadError struct {
+	Type string
+	Err  error
+}
+
+// Error implements error
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("task %s: invalid payload: %s", e.Type, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *PayloadError) Unwrap() error {
+	return e.Err
+}
+
+// Task describes a task type with a payload of type P
+type Task[P any] struct {
+	Type  string
+	Codec Codec
+	Opts  []asynq.Option
+}
+
+// NewTask creates a task definition for type name, opts are used for every enqueued task and can be overridden per call
+func NewTask[P any](name string, opts ...asynq.Option) *Task[P] {
+	return &Task[P]{
+		Type:  name,
+		Codec: JSON,
+		Opts:  opts,
+	}
+}
+
+// WithCodec sets the payload codec
+func (t *Task[P]) WithCodec(c Codec) *Task[P] {
+	t.Codec = c
+
+	return t
+}
+
+// Encode validates payload and creates an asynq task from it, options are passed when enqueuing
+func (t *Task[P]) Encode(payload P) (*asynq.Task, error) {
+	if err := validate.Struct(payload); err != nil {
+		return nil, &PayloadError{t.Type, err}
+	}
+
+	data, err := t.Codec.Marshal(payload)
+	if err != nil {
+		return nil, &PayloadError{t.Type, err}
+	}
+
+	return asynq.NewTask(t.Type, data), nil
+}
+
+// Decode decodes and validates the payload of task, the trace context added by Enqueue is skipped
+func (t *Task[P]) Decode(task *asynq.Task) (P, error) {
+	var payload P
+
+	_, data := unwrap(task.Payload())
+
+	if err := t.Codec.Unmarshal(data, &payload); err != nil {
+		return payload, &PayloadError{t.Type, err}
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return payload, &PayloadError{t.Type, err}
+	}
+
+	return payload, nil
+}
+
+// Handle registers fn for tasks of type t, payloads that can't be decoded are not retried
+func Handle[P any](q *Queue, t *Task[P], fn func(ctx context.Context, payload P) error) *Queue {
+	return q.AddHandlerFunc(t.Type, func(ctx context.Context, task *asynq.Task) error {
+		payload, err := t.Decode(task)
+		if err != nil {
+			return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+		}
+
+		return fn(ctx, payload)
+	})
+}
+
+// Enqueue encodes payload and adds it to the queue as a task of type t, opts override the defaults of t.
+// The payload carries the trace context of ctx to the worker.
+func Enqueue[P any](ctx context.Context, c *Client, t *Task[P], payload P, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	ctx, span := startSend(ctx, t.Type)
+	defer span.End()
+
+	opts = append(append([]asynq.Option{}, t.Opts...), opts...)
+
+	task, err := t.Encode(payload)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	task = inject(ctx, task, opts)
+
+	return send(ctx, span, c.client, task, opts...)
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"unsafe"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/khvh/golain/queue"
+
+var (
+	// envelopePrefix starts payloads that carry metadata, asynq tasks have no headers
+	envelopePrefix = []byte(`{"golain_envelope":1,`)
+
+	retryCountKey = attribute.Key("messaging.asynq.retry_count")
+	taskTypeKey   = attribute.Key("messaging.asynq.task_type")
+)
+
+// envelope holds a payload with metadata. It is JSON so the monitor and other consumers can still read the
+// task, payloads that aren't JSON are kept as base64 in Data.
+type envelope struct {
+	Envelope int               `json:"golain_envelope"`
+	Meta     map[string]string `json:"meta"`
+	Payload  json.RawMessage   `json:"payload,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+}
+
+// wrap puts payload in an envelope with meta
+func wrap(meta map[string]string, payload []byte) []byte {
+	env := envelope{Envelope: 1, Meta: meta}
+
+	if json.Valid(payload) {
+		env.Payload = payload
+	} else {
+		env.Data = payload
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return payload
+	}
+
+	return data
+}
+
+// unwrap splits a payload created by wrap, payloads without metadata are returned as is
+func unwrap(data []byte) (map[string]string, []byte) {
+	if !bytes.HasPrefix(data, envelopePrefix) {
+		return nil, data
+	}
+
+	var env envelope
+
+	if err := json.Unmarshal(data, &env); err != nil || env.Envelope != 1 {
+		return nil, data
+	}
+
+	if env.Payload != nil {
+		return env.Meta, env.Payload
+	}
+
+	return env.Meta, env.Data
+}
+
+// startSend starts the producer span of a task of type typ
+func startSend(ctx context.Context, typ string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, typ+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("asynq"),
+			taskTypeKey.String(typ),
+		),
+	)
+}
+
+// inject adds the trace context and baggage of ctx to the payload of task. Unique tasks are left as they
+// are, asynq detects duplicates by comparing payloads.
+func inject(ctx context.Context, task *asynq.Task, opts []asynq.Option) *asynq.Task {
+	for _, opt := range append(taskOpts(task), opts...) {
+		if opt.Type() == asynq.UniqueOpt {
+			return task
+		}
+	}
+
+	carrier := propagation.MapCarrier{}
+
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	if len(carrier) == 0 {
+		return task
+	}
+
+	return withPayload(task, wrap(carrier, task.Payload()))
+}
+
+// taskField returns the unexported field name of t, asynq offers no setters for them
+func taskField(t *asynq.Task, name string) (reflect.Value, bool) {
+	f := reflect.ValueOf(t).Elem().FieldByName(name)
+	if !f.IsValid() {
+		return f, false
+	}
+
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem(), true
+}
+
+// taskOpts returns the options task was created with
+func taskOpts(task *asynq.Task) []asynq.Option {
+	f, ok := taskField(task, "opts")
+	if !ok {
+		return nil
+	}
+
+	opts, _ := f.Interface().([]asynq.Option)
+
+	return opts
+}
+
+// withPayload returns a copy of task with payload. The copy keeps the options of task and, on the worker,
+// its ResultWriter.
+func withPayload(task *asynq.Task, payload []byte) *asynq.Task {
+	c := *task
+
+	f, ok := taskField(&c, "payload")
+	if !ok || f.Type() != reflect.TypeOf(payload) {
+		return asynq.NewTask(task.Type(), payload, taskOpts(task)...)
+	}
+
+	f.Set(reflect.ValueOf(payload))
+
+	return &c
+}
+
+// tracing is a worker middleware that continues the trace of the enqueuing request. The metadata is
+// removed before the task is passed on, so handlers see the payload that was enqueued.
+func tracing(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		if meta, payload := unwrap(t.Payload()); meta != nil {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(meta))
+			t = withPayload(t, payload)
+		}
+
+		attrs := []attribute.KeyValue{
+			semconv.MessagingSystemKey.String("asynq"),
+			semconv.MessagingOperationProcess,
+			taskTypeKey.String(t.Type()),
+		}
+
+		if id, ok := TaskID(ctx); ok {
+			attrs = append(attrs, semconv.MessagingMessageIDKey.String(id))
+		}
+
+		if queue, ok := QueueName(ctx); ok {
+			attrs = append(attrs, semconv.MessagingDestinationKey.String(queue))
+		}
+
+		if n, ok := RetryCount(ctx); ok {
+			attrs = append(attrs, retryCountKey.Int(n))
+		}
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, t.Type()+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		err := next.ProcessTask(ctx, t)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider and propagator recording the spans of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+// span returns the ended span called name
+func span(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, s := range recorder.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+
+	t.Fatalf("no span %q", name)
+
+	return nil
+}
+
+func TestAddContextPropagatesTrace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	q := NewQueue(NewMemoryBackend(1, Queues{defaultQueue: 1}))
+	defer q.Shutdown()
+
+	var got []byte
+
+	q.AddHandlerFunc("raw", func(ctx context.Context, task *asynq.Task) error {
+		got = task.Payload()
+
+		return nil
+	})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	q.Client().AddContext(ctx, asynq.NewTask("raw", []byte("not json")))
+	parent.End()
+
+	if n := q.Drain(context.Background()); n != 1 {
+		t.Fatalf("processed %d tasks, want 1", n)
+	}
+
+	if string(got) != "not json" {
+		t.Fatalf("handler got payload %q", got)
+	}
+
+	send, process := span(t, recorder, "raw send"), span(t, recorder, "raw process")
+
+	if send.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("send span is not a child of the request")
+	}
+
+	if process.Parent().SpanID() != send.SpanContext().SpanID() {
+		t.Fatal("process span is not a child of the send span")
+	}
+
+	if process.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Fatal("process span is in another trace")
+	}
+}
+
+func TestUniqueTaskKeepsPayload(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	task := asynq.NewTask("raw", []byte(`{"id":1}`), asynq.Unique(0))
+
+	if got := inject(ctx, task, nil); string(got.Payload()) != `{"id":1}` {
+		t.Fatalf("unique task payload changed to %s", got.Payload())
+	}
+}
+
+func TestWithPayloadKeepsOptions(t *testing.T) {
+	task := asynq.NewTask("raw", []byte("a"), asynq.Queue("critical"))
+
+	c := withPayload(task, []byte("b"))
+
+	if string(c.Payload()) != "b" || string(task.Payload()) != "a" {
+		t.Fatalf("payloads are %q and %q", c.Payload(), task.Payload())
+	}
+
+	opts := taskOpts(c)
+	if len(opts) != 1 || opts[0].Type() != asynq.QueueOpt {
+		t.Fatalf("options are %v", opts)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"strings"
 
 	"github.com/khvh/golain/oas"
+	"github.com/khvh/golain/validate"
 	"github.com/labstack/echo/v4"
 
+	"github.com/swaggest/jsonschema-go"
 	"github.com/swaggest/openapi-go/openapi3"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -100,6 +102,8 @@ type OASOptions struct {
 func InitReflector(port int, addresses []string, opts *OASOptions) *openapi3.Reflector {
 	ref := &openapi3.Reflector{}
 
+	ref.DefaultOptions = append(ref.DefaultOptions, jsonschema.InterceptProperty(validate.InterceptProperty))
+
 	if opts.OASVersion == "" {
 		opts.OASVersion = "3.1.0"
 	}
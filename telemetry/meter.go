@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/khvh/golain/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// Meter creates counters and histograms, instruments that can't be created are logged and record nothing
+type Meter struct {
+	meter metric.Meter
+}
+
+// Counter is a monotonic counter
+type Counter struct {
+	counter syncint64.Counter
+}
+
+// Histogram records a distribution of values
+type Histogram struct {
+	histogram syncfloat64.Histogram
+}
+
+// NewMeter returns a meter named name, usually the package or the component it measures
+func NewMeter(mp metric.MeterProvider, name string) *Meter {
+	if mp == nil {
+		mp = metric.NewNoopMeterProvider()
+	}
+
+	return &Meter{meter: mp.Meter(name)}
+}
+
+// Counter creates a counter, Prometheus exposes it with a _total suffix
+func (m *Meter) Counter(name, description string) *Counter {
+	c, err := m.meter.SyncInt64().Counter(name, instrument.WithDescription(description))
+	if err != nil {
+		logger.Component("telemetry").Err(err).Str("metric", name).Msg("Counter is disabled")
+
+		c, _ = metric.NewNoopMeter().SyncInt64().Counter(name)
+	}
+
+	return &Counter{counter: c}
+}
+
+// Histogram creates a histogram of values in u, e.g. unit.Milliseconds or unit.Bytes
+func (m *Meter) Histogram(name, description string, u unit.Unit) *Histogram {
+	h, err := m.meter.SyncFloat64().Histogram(name, instrument.WithDescription(description), instrument.WithUnit(u))
+	if err != nil {
+		logger.Component("telemetry").Err(err).Str("metric", name).Msg("Histogram is disabled")
+
+		h, _ = metric.NewNoopMeter().SyncFloat64().Histogram(name)
+	}
+
+	return &Histogram{histogram: h}
+}
+
+// Add increments the counter by n
+func (c *Counter) Add(ctx context.Context, n int64, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, n, attrs...)
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc(ctx context.Context, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, 1, attrs...)
+}
+
+// Record adds v to the histogram
+func (h *Histogram) Record(ctx context.Context, v float64, attrs ...attribute.KeyValue) {
+	h.histogram.Record(ctx, v, attrs...)
+}
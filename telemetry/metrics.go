@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const defaultMetricsInterval = time.Minute
+
+// MetricsConfig configures metrics. Registerer exposes them to Prometheus scrapes, an OTLP Exporter also
+// pushes them every Interval, a minute by default. Endpoint, Insecure and Headers work like in Config, an
+// empty Exporter only exposes metrics to Prometheus.
+type MetricsConfig struct {
+	ServiceName string
+	Version     string
+	Environment string
+	Attributes  map[string]string
+	Registerer  prometheus.Registerer
+	Exporter    Exporter
+	Endpoint    string
+	Insecure    bool
+	Headers     map[string]string
+	Interval    time.Duration
+}
+
+// NewMeterProvider creates a meter provider as described by cfg, shut it down to push the last metrics
+func NewMeterProvider(ctx context.Context, cfg MetricsConfig) (*sdkmetric.MeterProvider, error) {
+	res, err := newResource(ctx, cfg.ServiceName, cfg.Version, cfg.Environment, cfg.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+
+	if cfg.Registerer != nil {
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(cfg.Registerer))
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, sdkmetric.WithReader(exporter))
+	}
+
+	exporter, err := newMetricExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter != nil {
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = defaultMetricsInterval
+		}
+
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))))
+	}
+
+	return sdkmetric.NewMeterProvider(opts...), nil
+}
+
+// newMetricExporter creates the OTLP exporter of cfg, nil when metrics aren't pushed
+func newMetricExporter(ctx context.Context, cfg MetricsConfig) (sdkmetric.Exporter, error) {
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLPHTTP:
+		endpoint, insecure, path := parseEndpoint(cfg.Endpoint, defaultHTTPEndpoint, cfg.Insecure)
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint), otlpmetrichttp.WithHeaders(cfg.Headers)}
+
+		if insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+
+		if path != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(path))
+		}
+
+		return otlpmetrichttp.New(ctx, opts...)
+	case ExporterOTLPGRPC:
+		endpoint, insecure, _ := parseEndpoint(cfg.Endpoint, defaultGRPCEndpoint, cfg.Insecure)
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint), otlpmetricgrpc.WithHeaders(cfg.Headers)}
+
+		if insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+
+		return otlpmetricgrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported metric exporter %q", cfg.Exporter)
+	}
+}
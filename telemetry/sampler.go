@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// sampler samples new traces by the ratio set with SetSampleRatio, spans with a parent follow the parent
+var sampler = newRatioSampler(1)
+
+// ratioSampler is a trace ID ratio sampler whose ratio can be changed while spans are started
+type ratioSampler struct {
+	current atomic.Pointer[sdktrace.Sampler]
+}
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	s := &ratioSampler{}
+	s.set(ratio)
+
+	return s
+}
+
+func (s *ratioSampler) set(ratio float64) {
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	next := sdktrace.TraceIDRatioBased(ratio)
+	s.current.Store(&next)
+}
+
+// ShouldSample implements sdktrace.Sampler
+func (s *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*s.current.Load()).ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler
+func (s *ratioSampler) Description() string {
+	return (*s.current.Load()).Description()
+}
+
+// SetSampleRatio changes the share of new traces that are sampled while the app runs, 0 is treated as 1
+func SetSampleRatio(ratio float64) {
+	sampler.set(ratio)
+}
@@ -2,10 +2,16 @@ package telemetry
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
 
-	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -13,28 +19,183 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// New ...
-func New(id, url string) {
-	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
+// Exporter selects where spans are sent
+type Exporter string
+
+// Supported exporters
+const (
+	ExporterNone     Exporter = "none"
+	ExporterOTLPGRPC Exporter = "otlp-grpc"
+	ExporterOTLPHTTP Exporter = "otlp-http"
+	ExporterStdout   Exporter = "stdout"
+	ExporterFile     Exporter = "file"
+)
+
+const (
+	defaultHTTPEndpoint = "http://localhost:4318"
+	defaultGRPCEndpoint = "http://localhost:4317"
+)
+
+// Config configures tracing.
+//
+// Endpoint is host:port or a URL for the OTLP exporters, http://localhost:4318 for HTTP and http://localhost:4317
+// for gRPC by default. An http:// URL or Insecure disables TLS and a URL path replaces the default /v1/traces of
+// OTLP HTTP. For the file exporter it is the path of the file spans are
+// appended to. SampleRatio is the share of new traces that are sampled, 0 is treated as 1, spans with a parent
+// follow the sampling decision of the parent. SetSampleRatio changes it later.
+type Config struct {
+	ServiceName string
+	Version     string
+	Environment string
+	Exporter    Exporter
+	Endpoint    string
+	Insecure    bool
+	Headers     map[string]string
+	SampleRatio float64
+	Attributes  map[string]string
+}
+
+// ShutdownFunc flushes spans that are still batched and stops the tracer provider
+type ShutdownFunc func(ctx context.Context) error
+
+// New sets up the global tracer provider and the trace context and baggage propagators as described
+// by cfg, an empty Exporter exports over OTLP HTTP
+func New(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter == ExporterNone {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	res, err := newResource(ctx, cfg.ServiceName, cfg.Version, cfg.Environment, cfg.Attributes)
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		return nil, err
 	}
 
+	exporter, closeFn, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	SetSampleRatio(cfg.SampleRatio)
+
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
 		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String(id),
-			),
-		),
+		sdktrace.WithResource(res),
 	)
 
-	otel.
-		SetTracerProvider(tp)
-	otel.
-		SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+
+		if closeFn != nil {
+			if closeErr := closeFn(); err == nil {
+				err = closeErr
+			}
+		}
+
+		return err
+	}, nil
+}
+
+// newExporter creates the span exporter of cfg, closeFn is set for exporters that own a file
+func newExporter(ctx context.Context, cfg Config) (exporter sdktrace.SpanExporter, closeFn func() error, err error) {
+	switch cfg.Exporter {
+	case "", ExporterOTLPHTTP:
+		endpoint, insecure, path := parseEndpoint(cfg.Endpoint, defaultHTTPEndpoint, cfg.Insecure)
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithHeaders(cfg.Headers)}
+
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		if path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(path))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterOTLPGRPC:
+		endpoint, insecure, _ := parseEndpoint(cfg.Endpoint, defaultGRPCEndpoint, cfg.Insecure)
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithHeaders(cfg.Headers)}
+
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		if cfg.Endpoint == "" {
+			return nil, nil, fmt.Errorf("file exporter needs a path in Endpoint")
+		}
+
+		f, err := os.OpenFile(cfg.Endpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(f)); err != nil {
+			f.Close()
+
+			return nil, nil, err
+		}
+
+		return exporter, f.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported trace exporter %q", cfg.Exporter)
+	}
+
+	return exporter, nil, err
+}
+
+// parseEndpoint splits an endpoint into host:port, whether TLS is disabled and a URL path
+func parseEndpoint(endpoint, fallback string, insecure bool) (string, bool, string) {
+	if endpoint == "" {
+		endpoint = fallback
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, insecure, ""
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint, insecure, ""
+	}
+
+	return u.Host, insecure || u.Scheme == "http", strings.TrimSuffix(u.Path, "/")
+}
+
+// newResource describes the service, OTEL_RESOURCE_ATTRIBUTES are added as well
+func newResource(ctx context.Context, name, version, env string, extra map[string]string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String(name)}
+
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(version))
+	}
+
+	if env != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(env))
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, semconv.HostNameKey.String(host))
+	}
+
+	for k, v := range extra {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithFromEnv(),
+		resource.WithAttributes(attrs...),
+	)
 }
 
 // WithTracer returns a new tracer with name
@@ -0,0 +1,168 @@
+package validate
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/swaggest/jsonschema-go"
+)
+
+// InterceptProperty copies validate rules of a struct field into its JSON schema
+func InterceptProperty(name string, field reflect.StructField, propertySchema *jsonschema.Schema) error {
+	tag, ok := field.Tag.Lookup(Tag)
+	if !ok || tag == "-" {
+		return nil
+	}
+
+	r := Parse(tag)
+
+	if r.Required && propertySchema.Parent != nil && !contains(propertySchema.Parent.Required, name) {
+		propertySchema.Parent.Required = append(propertySchema.Parent.Required, name)
+	}
+
+	apply(propertySchema, r, field.Type)
+
+	return nil
+}
+
+// IsRequired reports whether the field of t named name in tag has the required rule
+func IsRequired(t reflect.Type, tag, name string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get(tag) == "" {
+			if IsRequired(sf.Type, tag, name) {
+				return true
+			}
+
+			continue
+		}
+
+		n, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+
+		if n == name {
+			return Parse(sf.Tag.Get(Tag)).Required
+		}
+	}
+
+	return false
+}
+
+func apply(s *jsonschema.Schema, r *Rules, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	for _, c := range r.Checks {
+		switch c.Name {
+		case "min", "max", "len":
+			applySize(s, c, t)
+		case "enum":
+			s.Enum = nil
+
+			for _, option := range strings.Split(c.Param, "|") {
+				s.Enum = append(s.Enum, enumValue(option, t))
+			}
+		case "email", "uuid":
+			s.WithFormat(c.Name)
+		}
+	}
+
+	if r.Dive == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if s.Items != nil && s.Items.SchemaOrBool != nil && s.Items.SchemaOrBool.TypeObject != nil {
+			apply(s.Items.SchemaOrBool.TypeObject, r.Dive, t.Elem())
+		}
+	case reflect.Map:
+		if s.AdditionalProperties != nil && s.AdditionalProperties.TypeObject != nil {
+			apply(s.AdditionalProperties.TypeObject, r.Dive, t.Elem())
+		}
+	}
+}
+
+func applySize(s *jsonschema.Schema, c Check, t reflect.Type) {
+	n, err := strconv.ParseFloat(c.Param, 64)
+	if err != nil {
+		return
+	}
+
+	i := int64(n)
+
+	switch t.Kind() {
+	case reflect.String:
+		if c.Name != "max" {
+			s.WithMinLength(i)
+		}
+
+		if c.Name != "min" {
+			s.WithMaxLength(i)
+		}
+	case reflect.Slice, reflect.Array:
+		if c.Name != "max" {
+			s.WithMinItems(i)
+		}
+
+		if c.Name != "min" {
+			s.WithMaxItems(i)
+		}
+	case reflect.Map:
+		if c.Name != "max" {
+			s.WithMinProperties(i)
+		}
+
+		if c.Name != "min" {
+			s.WithMaxProperties(i)
+		}
+	default:
+		if c.Name != "max" {
+			s.WithMinimum(n)
+		}
+
+		if c.Name != "min" {
+			s.WithMaximum(n)
+		}
+	}
+}
+
+func enumValue(option string, t reflect.Type) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(option, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(option, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(option); err == nil {
+			return b
+		}
+	}
+
+	return option
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,353 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tag is the struct tag holding validation rules, e.g. `validate:"required,min=3"`
+//
+// Supported rules are required, omitempty, min=N, max=N, len=N, enum=a|b|c, email, uuid and dive.
+// Rules after dive apply to the elements of a slice or the values of a map.
+// Regular expressions are read from the `pattern` tag so they can contain commas.
+const Tag = "validate"
+
+var (
+	nameTags = []string{"json", "query", "path", "header"}
+	uuidRe   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	patterns = sync.Map{}
+	fields   = sync.Map{}
+)
+
+// FieldError describes a single failed constraint
+type FieldError struct {
+	Field string
+	Rule  string
+	Param string
+	Msg   string
+}
+
+// Error implements error
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Msg)
+}
+
+// Errors is a list of failed constraints
+type Errors []*FieldError
+
+// Error implements error
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, ", ")
+}
+
+// Rules holds parsed validation rules for a single value
+type Rules struct {
+	Required  bool
+	OmitEmpty bool
+	Checks    []Check
+	Dive      *Rules
+}
+
+// Check is a single parameterised rule
+type Check struct {
+	Name  string
+	Param string
+}
+
+// Parse parses a validate tag
+func Parse(tag string) *Rules {
+	r := &Rules{}
+
+	parts := strings.Split(tag, ",")
+
+	for i, part := range parts {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch name {
+		case "":
+		case "required":
+			r.Required = true
+		case "omitempty":
+			r.OmitEmpty = true
+		case "dive":
+			r.Dive = Parse(strings.Join(parts[i+1:], ","))
+
+			return r
+		default:
+			r.Checks = append(r.Checks, Check{name, param})
+		}
+	}
+
+	return r
+}
+
+type field struct {
+	index   int
+	name    string
+	rules   *Rules
+	pattern string
+}
+
+// Struct validates v against its validate tags, returning Errors when any constraint fails.
+// Slices of structs are validated element by element.
+func Struct(v any) error {
+	var errs Errors
+
+	walk(reflect.ValueOf(v), "", &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func walk(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv := v.Field(f.index)
+			fp := join(path, f.name)
+
+			if f.rules == nil {
+				walk(fv, fp, errs)
+
+				continue
+			}
+
+			validateValue(fv, fp, f.rules, f.pattern, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateValue(v reflect.Value, path string, r *Rules, pattern string, errs *Errors) {
+	zero := !v.IsValid() || v.IsZero()
+
+	if r.Required && zero {
+		*errs = append(*errs, &FieldError{Field: path, Rule: "required", Msg: "is required"})
+
+		return
+	}
+
+	if zero && (r.OmitEmpty || v.Kind() == reflect.Pointer) {
+		return
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	for _, c := range r.Checks {
+		if err := check(v, c); err != nil {
+			err.Field = path
+			*errs = append(*errs, err)
+		}
+	}
+
+	if pattern != "" && v.Kind() == reflect.String {
+		re, err := compile(pattern)
+
+		if err != nil || !re.MatchString(v.String()) {
+			*errs = append(*errs, &FieldError{
+				Field: path,
+				Rule:  "pattern",
+				Param: pattern,
+				Msg:   fmt.Sprintf("must match %s", pattern),
+			})
+		}
+	}
+
+	switch {
+	case r.Dive != nil && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array):
+		for i := 0; i < v.Len(); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+
+			validateValue(v.Index(i), p, r.Dive, "", errs)
+		}
+	case r.Dive != nil && v.Kind() == reflect.Map:
+		iter := v.MapRange()
+
+		for iter.Next() {
+			p := fmt.Sprintf("%s[%v]", path, iter.Key())
+
+			validateValue(iter.Value(), p, r.Dive, "", errs)
+		}
+	case v.Kind() == reflect.Struct:
+		walk(v, path, errs)
+	}
+}
+
+func check(v reflect.Value, c Check) *FieldError {
+	fail := func(msg string, args ...any) *FieldError {
+		return &FieldError{Rule: c.Name, Param: c.Param, Msg: fmt.Sprintf(msg, args...)}
+	}
+
+	switch c.Name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(c.Param, 64)
+		if err != nil {
+			return fail("has an invalid %s rule", c.Name)
+		}
+
+		size, unit, ok := measure(v)
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case c.Name == "min" && size < limit:
+			return fail("must be at least %s%s", c.Param, unit)
+		case c.Name == "max" && size > limit:
+			return fail("must be at most %s%s", c.Param, unit)
+		case c.Name == "len" && size != limit:
+			return fail("must be exactly %s%s", c.Param, unit)
+		}
+	case "enum":
+		value := fmt.Sprint(v.Interface())
+
+		for _, option := range strings.Split(c.Param, "|") {
+			if option == value {
+				return nil
+			}
+		}
+
+		return fail("must be one of [%s]", strings.ReplaceAll(c.Param, "|", ", "))
+	case "email":
+		if v.Kind() != reflect.String {
+			return nil
+		}
+
+		if addr, err := mail.ParseAddress(v.String()); err != nil || addr.Address != v.String() {
+			return fail("must be a valid email address")
+		}
+	case "uuid":
+		if v.Kind() == reflect.String && !uuidRe.MatchString(v.String()) {
+			return fail("must be a valid UUID")
+		}
+	}
+
+	return nil
+}
+
+// measure returns the comparable size of v, numbers by value, strings by runes, collections by length
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+
+	return 0, "", false
+}
+
+func structFields(t reflect.Type) []field {
+	if cached, ok := fields.Load(t); ok {
+		return cached.([]field)
+	}
+
+	fs := []field{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		f := field{index: i, name: FieldName(sf), pattern: sf.Tag.Get("pattern")}
+
+		if tag, ok := sf.Tag.Lookup(Tag); ok {
+			if tag == "-" {
+				continue
+			}
+
+			f.rules = Parse(tag)
+		} else if f.pattern != "" {
+			f.rules = &Rules{}
+		}
+
+		if sf.Anonymous && f.rules == nil {
+			f.name = ""
+		}
+
+		fs = append(fs, f)
+	}
+
+	fields.Store(t, fs)
+
+	return fs
+}
+
+// FieldName returns the name used for a field in requests, the first of its json, query, path or header tags
+func FieldName(sf reflect.StructField) string {
+	for _, tag := range nameTags {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
+
+func join(path, name string) string {
+	switch {
+	case name == "":
+		return path
+	case path == "":
+		return name
+	default:
+		return path + "." + name
+	}
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns.Store(pattern, re)
+
+	return re, nil
+}